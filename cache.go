@@ -2,178 +2,2444 @@ package lru
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Clock represents a source of the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of a func as a Clock
+type ClockFunc func() time.Time
+
+// Now returns the current time
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// expiredVictimScan is the number of items in eviction order that are checked for an
+// expired item before an item is evicted to make room for a new item
+const expiredVictimScan = 5
+
+// ErrClosed is returned when a closed cache is accessed
+var ErrClosed = errors.New("cache is closed")
+
+// ErrCreateTimeout is returned when a create func does not complete within the
+// create timeout
+var ErrCreateTimeout = errors.New("create func timed out")
+
+// ErrCreatePanic is returned when a create func panics and Options.RecoverCreate is set.
+// If it is not set then the error is returned to requests waiting on the create func
+// and the panic is propagated to the request that invoked it. For a CreateContext func
+// or a create timeout the panic is propagated once the create func returns, provided the
+// request is still waiting for it. Background refreshes are not invoked by a request,
+// so a panic in a refresh crashes the process unless RecoverCreate is set.
+var ErrCreatePanic = errors.New("create func panicked")
+
+// ErrNotFound is returned by GetOrLoad when none of the loaders find the key
+var ErrNotFound = errors.New("key not found")
+
+// ErrEmptyKey is returned when a request has an empty key and Options.RejectEmptyKeys is set
+var ErrEmptyKey = errors.New("key is empty")
+
+// Unlimited can be specified as the cache capacity to disable capacity eviction
+const Unlimited = -1
+
 // UTCNow returns the current UTC time
 var UTCNow = func() time.Time {
 	return time.Now().UTC()
 }
 
-// Options represents a set of LRU cache options
-type Options struct {
+type (
+	// Options represents a set of LRU cache options
+	Options = TypedOptions[string, interface{}]
+
+	// Cache represents an LRU memory cache
+	Cache = TypedCache[string, interface{}]
+
+	// GetOrAdd represents a cache GetOrAdd request
+	GetOrAdd = TypedGetOrAdd[string, interface{}]
+
+	// Item represents a cached value
+	Item = TypedItem[string, interface{}]
+
+	// BytesOptions represents a set of byte slice cache options
+	BytesOptions = TypedOptions[string, []byte]
+
+	// BytesCache represents an LRU memory cache of byte slices
+	BytesCache = TypedCache[string, []byte]
+
+	// BytesGetOrAdd represents a byte slice cache GetOrAdd request
+	BytesGetOrAdd = TypedGetOrAdd[string, []byte]
+)
+
+// TypedOptions represents a set of typed LRU cache options
+type TypedOptions[K comparable, V any] struct {
+	// Capacity limits the total weight of the cached items. Items have a weight
+	// of one unless specified by the GetOrAdd request, in which case Capacity
+	// limits the number of items. If zero then a capacity of 100 is used. If
+	// Unlimited or any other negative value then items are never evicted to make
+	// room for new items.
 	Capacity int
-	Policy   ExpirationPolicy
+	Policy   TypedExpirationPolicy[K, V]
+
+	// InitialCapacity is the number of items for which space is allocated when the cache
+	// is created or cleared, which reduces allocations while the cache is filled. It does
+	// not limit the number of items.
+	InitialCapacity int
+
+	// Clock is the time source for the cache. If nil then UTCNow is used.
+	Clock Clock
+
+	// Rand is the random source for the cache, which is used by randomized policies
+	// such as JitteredExpirationPolicy and SampledEvictionPolicy. It is only used while the cache lock is held,
+	// so must not be used elsewhere. If nil then the math/rand package source is used.
+	Rand *rand.Rand
+
+	// MaxBytes limits the total size of the cached values as reported by Sizer.
+	// If both Capacity and MaxBytes are set then both limits are enforced. If only
+	// MaxBytes is set then the number of items is not limited.
+	MaxBytes int64
+
+	// Sizer returns the size of a value. It is invoked once when the value is added.
+	// If nil and MaxBytes is set then EstimateSize is used, otherwise values have a
+	// size of zero.
+	Sizer func(V) int64
+
+	// NegativeTTL enables negative caching. If positive then a create func error is
+	// cached for the specified duration, during which requests for the key return the
+	// error without invoking the create func. Negative entries count towards the cache
+	// capacity and are evicted in eviction order along with other items, but expire
+	// regardless of the expiration policy and ItemEvicted is not invoked when they are
+	// removed. Their eviction is not counted in Stats or recorded in the ghost list.
+	// Requests served by a negative entry are hits.
+	NegativeTTL time.Duration
+
+	// RefreshThreshold enables refresh-ahead for expiration policies that do not
+	// implement TypedStaleExpirationPolicy. If positive then a hit for an item that
+	// expires within the threshold returns the current value and invokes the create func
+	// in the background to replace it. Only one create func is invoked per key at a time.
+	// Refresh-ahead is not used with NoExpirationPolicy.
+	RefreshThreshold time.Duration
+
+	// SkipNilValues prevents create func results that are nil from being cached. Nil
+	// results are returned to the request, but the next request invokes the create func.
+	SkipNilValues bool
+
+	// CreateTimeout limits the time that GetOrAdd requests wait for a create func,
+	// after which ErrCreateTimeout is returned. If positive then the create func is
+	// invoked in a separate goroutine and is not cancelled, so that the result is
+	// cached when it completes, regardless of whether the requests have returned.
+	// The request context does not prevent the result from being cached.
+	CreateTimeout time.Duration
+
+	// Loader is invoked by Get, and by GetOrAdd requests without a create func, to
+	// create the value for a key that is not cached.
+	Loader func(K) (V, error)
+
+	// MaxConcurrentCreates limits the number of create funcs, including background
+	// refreshes, that are invoked concurrently for the cache. Further create funcs wait
+	// for a running func to complete. If the context of the request that invokes the
+	// create func is done while it waits then the func is not invoked and the context
+	// error is returned to the requests waiting for it. If zero then the number of
+	// concurrent create funcs is not limited.
+	MaxConcurrentCreates int
+
+	// RejectEmptyKeys rejects keys that are the zero value of the key type, such as an empty
+	// string, which are otherwise cached as any other key. GetOrAdd and the funcs that are
+	// equivalent to it return ErrEmptyKey for an empty key, and Set and SetMulti do not add
+	// items with an empty key. This prevents requests with keys that were not set by mistake
+	// from sharing a single item.
+	RejectEmptyKeys bool
+
+	// RecoverCreate recovers a panic in a create func, including the loader and background
+	// refreshes, and returns an error that wraps ErrCreatePanic and describes the panic value
+	// in its place. The error is handled as any other create func error, so it is returned
+	// to all requests waiting for the result and is cached if NegativeTTL is set.
+	RecoverCreate bool
+
+	// LockWaitThreshold is the time that GetOrAdd must wait to acquire the cache lock
+	// before OnLockWait is invoked. If zero then OnLockWait is invoked for every wait.
+	LockWaitThreshold time.Duration
+
+	// BatchSize limits the number of items that SetMulti and Load add under a single lock
+	// acquisition. Items are added in batches and the lock is released between batches,
+	// which allows other operations to proceed during a bulk load at the cost of the load
+	// not being atomic. If zero then all items are added under a single lock acquisition.
+	BatchSize int
+
+	// ThrashWindow is the number of GetOrAdd lookups after which the capacity eviction
+	// rate, which is the number of capacity evictions per lookup, is compared with
+	// ThrashRate. If the rate is at least ThrashRate then OnThrash is invoked with the
+	// rate. If zero then the rate is not checked.
+	ThrashWindow int
+
+	// ThrashRate is the eviction rate at which OnThrash is invoked. If zero then a rate
+	// of 0.9 is used.
+	ThrashRate float64
+
+	// GhostSize is the number of keys of items evicted to make room for a new item that
+	// are retained, without their values, in a ghost list. A miss for a key in the ghost
+	// list is counted in Stats.GhostHits, which is the number of misses that a larger
+	// capacity would have served. If zero then evicted keys are not retained.
+	GhostSize int
+
+	// Equal reports whether two values are equal for CompareAndSwap. If nil then
+	// values are compared using ==.
+	Equal func(V, V) bool
+
+	// UnlockedEvicted defers ItemEvicted and ItemsEvicted until the cache lock has been
+	// released by the operation that removed the items, so that the funcs can call cache
+	// methods. The removed items are no longer cached when the funcs are invoked, but
+	// other operations may run before the funcs complete.
+	UnlockedEvicted bool
+
+	// AutoCloseValues invokes Close on the values of removed items that implement io.Closer
+	// once ItemEvicted or ItemsEvicted has been invoked for them, including items that are
+	// evicted, expire, are removed or are replaced. Errors are passed to OnCloseError. A
+	// replaced value is not closed if it is the same value as its replacement, unless the
+	// value is not comparable, and values returned by Take are not closed.
+	AutoCloseValues bool
+
+	// ExpiryTimers schedules a timer for each item with an expiry, which removes the item
+	// and invokes ItemEvicted with EvictExpired when it expires. If the expiry has been
+	// extended by the expiration policy then the timer is rescheduled. Timers are stopped
+	// when items are removed. Timers use the system time rather than Clock and each one
+	// has a cost, so they are only suited to caches with a small number of items.
+	ExpiryTimers bool
+
+	// ReapInterval enables a background goroutine that removes expired items at
+	// the specified interval. Close must be called to stop the goroutine.
+	// If zero then items are only removed when they are accessed.
+	ReapInterval time.Duration
+
+	// Eviction returns a new eviction policy for the cache. The func is invoked for
+	// each cache instance, so that policy state is never shared. If nil then
+	// NewTypedLRUEvictionPolicy is used.
+	Eviction func() TypedEvictionPolicy[K, V]
+
+	// DisableLRU uses NewTypedFIFOEvictionPolicy if Eviction is nil, so that hits do not
+	// update the recency of items and items are evicted in the order that they were added.
+	// It is intended for caches where item lifetime is determined by the expiration policy.
+	DisableLRU bool
 }
 
 // NewCache returns a new LRU cache
 func NewCache(o Options) *Cache {
-	var cap int
-	if o.Capacity > 0 {
-		cap = o.Capacity
+	return NewTypedCache(o)
+}
+
+// NewBytesCache returns a new LRU cache of byte slices. Values are stored without being
+// boxed in an interface and if Sizer is nil then the size of a value is its length, so
+// MaxBytes limits the total length of the cached values.
+func NewBytesCache(o BytesOptions) *BytesCache {
+	if o.Sizer == nil {
+		o.Sizer = func(v []byte) int64 { return int64(len(v)) }
+	}
+
+	return NewTypedCache(o)
+}
+
+// NewTypedCache returns a new typed LRU cache
+func NewTypedCache[K comparable, V any](o TypedOptions[K, V]) *TypedCache[K, V] {
+	var sz func(V) int64
+	if o.Sizer != nil {
+		sz = o.Sizer
+	} else if o.MaxBytes > 0 {
+		sz = func(v V) int64 { return EstimateSize(v) }
 	} else {
-		cap = 100
+		sz = func(V) int64 { return 0 }
 	}
 
-	var pol ExpirationPolicy
+	var pol TypedExpirationPolicy[K, V]
 	if o.Policy != nil {
 		pol = o.Policy
 	} else {
-		pol = NewNoExpirationPolicy()
+		pol = NewTypedNoExpirationPolicy[K, V]()
+	}
+
+	var clk Clock
+	if o.Clock != nil {
+		clk = o.Clock
+	} else {
+		clk = ClockFunc(func() time.Time { return UTCNow() })
+	}
+
+	var eq func(V, V) bool
+	if o.Equal != nil {
+		eq = o.Equal
+	} else {
+		eq = func(a, b V) bool { return equal(a, b) }
+	}
+
+	thrashRate := o.ThrashRate
+	if thrashRate <= 0 {
+		thrashRate = 0.9
+	}
+
+	var newEv func() TypedEvictionPolicy[K, V]
+	if o.Eviction != nil {
+		newEv = o.Eviction
+	} else if o.DisableLRU {
+		newEv = NewTypedFIFOEvictionPolicy[K, V]
+	} else {
+		newEv = NewTypedLRUEvictionPolicy[K, V]
+	}
+
+	ev := func() TypedEvictionPolicy[K, V] {
+		p := newEv()
+		if rp, ok := p.(randomEvictionPolicy); ok {
+			rp.useRand(o.Rand)
+		}
+
+		return p
+	}
+
+	c := &TypedCache[K, V]{
+		ItemEvicted:       func(*TypedItem[K, V], EvictReason) {},
+		ItemAdded:         func(*TypedItem[K, V]) {},
+		CanEvict:          func(*TypedItem[K, V]) bool { return true },
+		OnHit:             func(K) {},
+		OnMiss:            func(K) {},
+		OnCreate:          func(K, time.Duration) {},
+		OnThrash:          func(float64) {},
+		OnStore:           func(_ K, v V) (V, error) { return v, nil },
+		OnCloseError:      func(K, error) {},
+		cap:               capacityOrDefault(o.Capacity, o.MaxBytes),
+		maxBytes:          o.MaxBytes,
+		negativeTTL:       o.NegativeTTL,
+		refresh:           o.RefreshThreshold,
+		skipNil:           o.SkipNilValues,
+		createTimeout:     o.CreateTimeout,
+		thrashWindow:      o.ThrashWindow,
+		batchSize:         o.BatchSize,
+		recoverCreate:     o.RecoverCreate,
+		rejectEmpty:       o.RejectEmptyKeys,
+		lockWaitThreshold: o.LockWaitThreshold,
+		thrashRate:        thrashRate,
+		ghostSize:         o.GhostSize,
+		unlocked:          o.UnlockedEvicted,
+		autoClose:         o.AutoCloseValues,
+		timers:            o.ExpiryTimers,
+		sizer:             sz,
+		equal:             eq,
+		loader:            o.Loader,
+		policy:            pol,
+		clock:             clk,
+		rand:              o.Rand,
+		newEviction:       ev,
+		eviction:          ev(),
+		initialCap:        max(o.InitialCapacity, 0),
+		items:             make(map[K]*TypedItem[K, V], max(o.InitialCapacity, 0)),
+		tags:              map[string]map[K]struct{}{},
+		calls:             map[K]*call[V]{},
+		mu:                &sync.RWMutex{},
+	}
+
+	c.shared.Store(c.sharedAccess())
+
+	if o.MaxConcurrentCreates > 0 {
+		c.creates = make(chan struct{}, o.MaxConcurrentCreates)
+	}
+
+	if o.GhostSize > 0 {
+		c.ghosts = newGhostList[K]()
 	}
 
-	return &Cache{
-		ItemEvicted: func(*Item) {},
-		cap:         cap,
-		policy:      pol,
-		items:       map[string]*list.Element{},
-		lru:         list.New(),
-		mu:          &sync.Mutex{},
+	if o.ReapInterval > 0 {
+		c.stop = make(chan struct{})
+		c.stopped = make(chan struct{})
+
+		go c.reap(o.ReapInterval)
 	}
+
+	return c
 }
 
-// Cache represents an LRU memory cache
-type Cache struct {
-	ItemEvicted func(*Item)
-	cap         int
-	policy      ExpirationPolicy
-	items       map[string]*list.Element
-	lru         *list.List
-	mu          *sync.Mutex
+// TypedCache represents a typed LRU memory cache
+type TypedCache[K comparable, V any] struct {
+	ItemEvicted func(*TypedItem[K, V], EvictReason)
+
+	// ItemsEvicted is invoked with the items removed by a bulk operation, which are
+	// Clear, Close, Resize, RemoveFunc, InvalidateTag, RemoveExpired and each pass of the
+	// ReapInterval goroutine. If set then ItemEvicted is not invoked for those items, but
+	// is still invoked for items that are removed individually. ItemsEvicted is nil by
+	// default.
+	ItemsEvicted func([]*TypedItem[K, V], EvictReason)
+
+	// ItemAdded is invoked when an item is added for a key that is not cached. It is
+	// not invoked when an existing item is accessed or replaced. ItemAdded is invoked
+	// while the cache lock is held, including if UnlockedEvicted is set, so it must not
+	// call any cache methods.
+	ItemAdded func(*TypedItem[K, V])
+
+	// CanEvict is invoked for each item selected by the eviction policy to make room for
+	// a new item. If it returns false then the item is retained and the next item is
+	// selected. The built-in eviction policies leave the retained item in place, whereas
+	// it is added back to other policies as a newly added item. If no item can be evicted
+	// then the item is added and the cache exceeds its capacity. CanEvict may be invoked
+	// more than once for an item and is invoked while the cache lock is held, so it must
+	// not call any cache methods.
+	CanEvict func(*TypedItem[K, V]) bool
+
+	// OnHit is invoked by GetOrAdd when a live item is found. OnMiss is invoked by
+	// GetOrAdd when a live item is not found, including by requests that wait on an
+	// in-flight create func. Neither is invoked while the cache lock is held.
+	OnHit  func(K)
+	OnMiss func(K)
+
+	// OnCreate is invoked with the elapsed time after each create func invocation,
+	// including background refreshes. It is not invoked while the cache lock is held.
+	OnCreate func(K, time.Duration)
+
+	// OnThrash is invoked with the eviction rate of each ThrashWindow that reaches
+	// ThrashRate, which indicates that the capacity is too small for the working set.
+	// It is not invoked while the cache lock is held.
+	OnThrash func(rate float64)
+
+	// OnLockWait is invoked by GetOrAdd with the time spent waiting to acquire the cache
+	// lock if it exceeds LockWaitThreshold, which indicates lock contention. The wait is
+	// only measured if the lock is held by another operation. It is invoked once the
+	// request has completed and the lock has been released. OnLockWait is nil by default.
+	OnLockWait func(time.Duration)
+
+	// OnStore is invoked with each value returned by a create func, including background
+	// refreshes, before it is cached. The returned value is cached in its place, which
+	// allows the value to be copied or validated. If an error is returned then the value
+	// is not cached and the error is returned to the request, as for a create func error.
+	// It is not invoked while the cache lock is held or for values added by Set.
+	OnStore func(K, V) (V, error)
+
+	// OnCloseError is invoked with the item key and the error if closing an item value
+	// fails when Options.AutoCloseValues is set. Values are closed where ItemEvicted is
+	// invoked, so it is invoked while the cache lock is held unless UnlockedEvicted is set.
+	OnCloseError func(K, error)
+
+	cap               int
+	maxBytes          int64
+	initialCap        int
+	negativeTTL       time.Duration
+	refresh           time.Duration
+	skipNil           bool
+	createTimeout     time.Duration
+	thrashWindow      int
+	batchSize         int
+	recoverCreate     bool
+	rejectEmpty       bool
+	lockWaitThreshold time.Duration
+	thrashRate        float64
+	ghostSize         int
+	ghosts            *ghostList[K]
+	unlocked          bool
+	autoClose         bool
+	timers            bool
+	pending           []eviction[K, V]
+	bytes             int64
+	weight            int
+	itemPolicies      int
+	sizer             func(V) int64
+	equal             func(V, V) bool
+	loader            func(K) (V, error)
+	policy            TypedExpirationPolicy[K, V]
+	clock             Clock
+	rand              *rand.Rand
+	newEviction       func() TypedEvictionPolicy[K, V]
+	eviction          TypedEvictionPolicy[K, V]
+	items             map[K]*TypedItem[K, V]
+	tags              map[string]map[K]struct{}
+	calls             map[K]*call[V]
+	creates           chan struct{}
+	stats             counters
+	shared            atomic.Bool
+	closed            bool
+	stop              chan struct{}
+	stopped           chan struct{}
+	mu                *sync.RWMutex
 }
 
 // GetOrAdd returns the cached item with the request key if it exists.
 // If the key does not exist then the create func is invoked and the result cached.
-func (c *Cache) GetOrAdd(r *GetOrAdd) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// If the create func returns an error then nothing is cached and the error is returned.
+func (c *TypedCache[K, V]) GetOrAdd(r *TypedGetOrAdd[K, V]) error {
+	return c.GetOrAddContext(context.Background(), r)
+}
+
+// GetOrAddFunc is equivalent to GetOrAdd, but builds the request from the specified
+// key, TTL and create func and returns the result
+func (c *TypedCache[K, V]) GetOrAddFunc(key K, ttl time.Duration, create func() (V, error)) (V, error) {
+	r := TypedGetOrAdd[K, V]{Key: key, TTL: ttl, Create: create}
+	err := c.GetOrAdd(&r)
 
-	var el *list.Element
-	var i *Item
+	return r.Result, err
+}
 
-	if el, ok := c.items[r.Key]; ok {
-		i = el.Value.(*Item)
+// Get returns the cached value for the specified key if it exists. If the key does not
+// exist then Options.Loader is invoked and the result cached with the specified TTL,
+// as with GetOrAdd. Get panics if Options.Loader is nil.
+func (c *TypedCache[K, V]) Get(key K, ttl time.Duration) (V, error) {
+	r := TypedGetOrAdd[K, V]{Key: key, TTL: ttl}
+	err := c.GetOrAdd(&r)
 
-		if err := c.policy.Apply(i); err == nil {
-			c.lru.MoveToBack(el)
+	return r.Result, err
+}
 
-			r.Result = i.Value
+// Warm loads the specified keys concurrently using the loader func and caches the
+// results with the specified TTL. Keys are loaded by up to GOMAXPROCS goroutines using
+// GetOrAdd, so existing items are not replaced and loads are deduplicated with other
+// requests. The loader errors are joined and returned once all keys have been loaded.
+func (c *TypedCache[K, V]) Warm(keys []K, loader func(K) (V, error), ttl time.Duration) error {
+	return warm(keys, func(k K) error {
+		_, err := c.GetOrAddFunc(k, ttl, func() (V, error) {
+			return loader(k)
+		})
+		return err
+	})
+}
+
+// warm invokes the func for each key using up to GOMAXPROCS goroutines and returns
+// the joined errors
+func warm[K comparable](keys []K, fn func(K) error) error {
+	ch := make(chan int)
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for n := min(runtime.GOMAXPROCS(0), len(keys)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := range ch {
+				if err := fn(keys[idx]); err != nil {
+					errs[idx] = fmt.Errorf("%v: %w", keys[idx], err)
+				}
+			}
+		}()
+	}
+
+	for idx := range keys {
+		ch <- idx
+	}
+	close(ch)
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// GetOrLoad is equivalent to GetOrAddFunc, but the value is created by invoking the
+// loaders in order until one of them finds the key, which allows a chain of backing
+// stores to be searched. The value found by the first loader to return true is cached
+// with the specified TTL. If a loader returns an error then the remaining loaders are
+// not invoked and the error is returned. If no loader finds the key then ErrNotFound
+// is returned.
+func (c *TypedCache[K, V]) GetOrLoad(key K, ttl time.Duration, loaders ...func(K) (V, bool, error)) (V, error) {
+	return c.GetOrAddFunc(key, ttl, func() (V, error) {
+		return load(key, loaders)
+	})
+}
+
+// load invokes the loaders in order and returns the value found by the first loader
+// to find the key
+func load[K comparable, V any](key K, loaders []func(K) (V, bool, error)) (V, error) {
+	for _, l := range loaders {
+		v, ok, err := l(key)
+		if err != nil || ok {
+			return v, err
+		}
+	}
+
+	var v V
+	return v, ErrNotFound
+}
+
+// GetOrAddWithTTLFunc is equivalent to GetOrAdd, but builds the request from the
+// specified key and create func, which returns the TTL for the created value
+func (c *TypedCache[K, V]) GetOrAddWithTTLFunc(key K, create func() (V, time.Duration, error)) (V, error) {
+	r := TypedGetOrAdd[K, V]{Key: key, CreateWithTTL: create}
+	err := c.GetOrAdd(&r)
+
+	return r.Result, err
+}
+
+// GetOrAddOnce is equivalent to GetOrAddFunc, but the created value does not expire and
+// is pinned, so that the create func is invoked at most once for the key unless the item
+// is explicitly removed. Errors are not cached, regardless of NegativeTTL, so a failed
+// create func is invoked again by the next request.
+func (c *TypedCache[K, V]) GetOrAddOnce(key K, create func() (V, error)) (V, error) {
+	r := TypedGetOrAdd[K, V]{
+		Key:    key,
+		Create: create,
+		Policy: NewTypedNoExpirationPolicy[K, V](),
+		once:   true,
+	}
+	err := c.GetOrAdd(&r)
+
+	return r.Result, err
+}
+
+// GetOrAddContext is equivalent to GetOrAdd, but returns the context error if the
+// context is cancelled before the result is available. The create func result is
+// not cached if the context is cancelled while it is being invoked.
+//
+// Concurrent requests for the same key are deduplicated, so that only one create func
+// is invoked at a time and the remaining requests wait for its result. The cache lock
+// is not held while the create func is invoked, so other keys can be accessed
+// concurrently. If the key is added by another operation in the meantime then the
+// existing item is returned and the create func result is discarded.
+//
+// If the request has a CreateContext func then it is invoked in a separate goroutine
+// with a context that carries the values of the request context, but that is only
+// cancelled once the contexts of all requests waiting for the result are done. The
+// create func is therefore not cancelled while any request is waiting, and the result
+// is not cached if it is cancelled. If CreateTimeout is set then the context is never
+// cancelled, as the result is always cached.
+func (c *TypedCache[K, V]) GetOrAddContext(ctx context.Context, r *TypedGetOrAdd[K, V]) error {
+	r.Created = false
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.rejected(r.Key) {
+		return ErrEmptyKey
+	}
+
+	if c.shared.Load() && !r.Force {
+		if v, ok := c.getShared(r.Key); ok {
+			c.OnHit(r.Key)
+			c.checkThrash()
+
+			r.Result = v
+			return nil
+		}
+	}
+
+	if d := c.lockWait(); d > 0 {
+		// the wait is reported once the lock has been released
+		defer c.OnLockWait(d)
+	}
+
+	if c.closed {
+		c.unlock()
+		return ErrClosed
+	}
+
+	if i, ok := c.items[r.Key]; ok && !r.Force {
+		if err := c.apply(i); err == nil {
+			c.access(i)
+			c.stats.hits.Add(1)
+
+			v, err := i.Value, i.err
+			if err == nil {
+				c.refreshAhead(ctx, i, r)
+			}
+			c.unlock()
+
+			c.OnHit(r.Key)
+			c.checkThrash()
+			if err != nil {
+				return err
+			}
+
+			r.Result = v
 			return nil
 		}
 
 		// item has expired
-		c.lru.Remove(el)
+		c.remove(i, EvictExpired)
+	}
+
+	c.recordMiss(r.Key)
+
+	if cl, ok := c.calls[r.Key]; ok && !r.Force && cl.join() {
+		c.unlock()
+		defer cl.leave()
+
+		c.OnMiss(r.Key)
+		c.checkThrash()
+		v, err := cl.wait(ctx, c.createTimeout)
+		if err != nil {
+			return err
+		}
+
+		r.Result = v
+		return nil
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+	if r.CreateContext != nil && r.CreateWithTTL == nil && c.createTimeout <= 0 {
+		cl.ctx, cl.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		cl.join()
+	}
+	if r.Force {
+		// in-flight calls, including refreshes, may have started before the update
+		c.supersede(r.Key)
+	}
+	c.calls[r.Key] = cl
+	c.unlock()
+
+	r.Created = true
+	c.OnMiss(r.Key)
+	c.checkThrash()
+	if cl.ctx == nil && c.createTimeout <= 0 {
+		return c.create(ctx, r, cl)
+	}
+
+	cctx := cl.ctx
+	if cctx == nil {
+		// the create func is detached from the request so that the result is cached
+		// if the request times out
+		cctx = context.WithoutCancel(ctx)
+	}
+
+	cr := *r
+	go func() {
+		// the panic is propagated to the request if it is still waiting, rather than
+		// crashing the process
+		defer func() {
+			recover()
+		}()
+
+		c.create(cctx, &cr, cl)
+	}()
+	defer cl.leave()
+
+	v, err := cl.wait(ctx, c.createTimeout)
+	if p := cl.panicked(); p != nil {
+		panic(p)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.Result = v
+	return nil
+}
+
+// create invokes the request create func and caches the result, releasing
+// any requests waiting on the call
+func (c *TypedCache[K, V]) create(ctx context.Context, r *TypedGetOrAdd[K, V], cl *call[V]) error {
+	defer close(cl.done)
+	if cl.cancel != nil {
+		defer cl.cancel()
+	}
+
+	ttl := c.invoke(ctx, r, cl)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	// the call is replaced if it was cancelled before the create func returned
+	if c.calls[r.Key] == cl {
+		delete(c.calls, r.Key)
+	}
+
+	if cl.err != nil {
+		if _, ok := c.items[r.Key]; !ok && c.negativeTTL > 0 && !r.once && !cl.superseded && ctx.Err() == nil && !c.closed {
+			c.addNegative(r.Key, cl.err)
+		}
+
+		return cl.err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if len(c.items) >= c.cap {
-		el = c.lru.Front()
-		i = el.Value.(*Item)
+	if c.closed {
+		return ErrClosed
+	}
 
-		c.lru.Remove(el)
-		delete(c.items, i.Key)
+	// the key may have been added while the create func was invoked
+	if i, ok := c.items[r.Key]; ok && (!r.Force || cl.superseded) {
+		if err := c.apply(i); err == nil && i.err == nil {
+			c.access(i)
 
-		c.ItemEvicted(i)
+			cl.val = i.Value
+			r.Result = i.Value
+			return nil
+		}
 	}
 
-	i = &Item{
-		Key:     r.Key,
-		Value:   r.Create(),
-		Expires: UTCNow().Add(r.TTL),
+	if cl.superseded || (c.skipNil && isNil(cl.val)) {
+		r.Result = cl.val
+		return nil
 	}
 
-	el = c.lru.PushBack(i)
-	c.items[r.Key] = el
+	i := c.set(r.Key, cl.val, ttl, r.Weight, r.Policy, r.Tags)
+	i.CreateDuration = cl.elapsed
+	if r.once {
+		i.pinned = true
+	}
 
 	r.Result = i.Value
 	return nil
 }
 
-// GetOrAdd represents a cache GetOrAdd request
-type GetOrAdd struct {
-	Key    string
-	TTL    time.Duration
-	Create func() interface{}
-	Result interface{}
+// invoke invokes the request create func once the number of concurrent create funcs
+// is within the limit and returns the TTL. The call value is set to the value returned
+// by OnStore and the call elapsed time to the create func duration.
+func (c *TypedCache[K, V]) invoke(ctx context.Context, r *TypedGetOrAdd[K, V], cl *call[V]) time.Duration {
+	if c.creates != nil {
+		select {
+		case c.creates <- struct{}{}:
+			defer func() { <-c.creates }()
+		case <-ctx.Done():
+			cl.err = ctx.Err()
+			return 0
+		}
+	}
+
+	var ttl time.Duration
+	func() {
+		// release the call if the create func panics so that subsequent requests retry
+		defer func() {
+			if p := recover(); p != nil {
+				var v V
+				cl.val, cl.err = v, fmt.Errorf("%w: %v", ErrCreatePanic, p)
+				cl.panic = p
+
+				c.mu.Lock()
+				if c.calls[r.Key] == cl {
+					delete(c.calls, r.Key)
+				}
+				c.unlock()
+
+				panic(p)
+			}
+		}()
+
+		start := time.Now()
+		defer func() {
+			cl.elapsed = time.Since(start)
+			c.OnCreate(r.Key, cl.elapsed)
+		}()
+
+		if c.recoverCreate {
+			defer func() {
+				if p := recover(); p != nil {
+					var v V
+					cl.val, cl.err = v, fmt.Errorf("%w: %v", ErrCreatePanic, p)
+				}
+			}()
+		}
+
+		cl.val, ttl, cl.err = r.create(ctx, c.loader)
+	}()
+	if cl.err != nil {
+		return ttl
+	}
+
+	cl.val, cl.err = c.OnStore(r.Key, cl.val)
+	return ttl
+}
+
+// GetMulti returns the values for the live items with the specified keys. The lock
+// is acquired once for all keys. The expiration policy is applied and the access is
+// recorded for each item found, as with GetOrAdd. Missing and expired keys are not
+// included in the result.
+func (c *TypedCache[K, V]) GetMulti(keys []K) map[K]V {
+	c.mu.Lock()
+	defer c.unlock()
+
+	res := make(map[K]V, len(keys))
+	for _, k := range keys {
+		i, ok := c.items[k]
+		if ok {
+			if err := c.apply(i); err != nil {
+				// item has expired
+				c.remove(i, EvictExpired)
+			} else if i.err == nil {
+				c.access(i)
+				c.stats.hits.Add(1)
+
+				res[k] = i.Value
+				continue
+			}
+		}
+
+		c.recordMiss(k)
+	}
+
+	return res
 }
 
-// Item represents a cached value
-type Item struct {
-	Key     string
-	Value   interface{}
-	Expires time.Time
+// refreshAhead invokes the request create func in the background if the item is stale
+// and a create func is not already in-flight for the key. The caller must hold the lock.
+func (c *TypedCache[K, V]) refreshAhead(ctx context.Context, i *TypedItem[K, V], r *TypedGetOrAdd[K, V]) {
+	if (!r.hasCreate() && c.loader == nil) || !c.stale(i) {
+		return
+	}
+	if _, ok := c.calls[i.Key]; ok {
+		return
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[i.Key] = cl
+
+	go c.refreshItem(context.WithoutCancel(ctx), *r, cl)
+}
+
+// stale returns true if the item is stale according to the expiration policy, or if
+// it expires within the refresh threshold. The caller must hold the lock.
+func (c *TypedCache[K, V]) stale(i *TypedItem[K, V]) bool {
+	p := c.policyFor(i)
+	if sp, ok := p.(TypedStaleExpirationPolicy[K, V]); ok {
+		return sp.Stale(i, c.clock.Now())
+	}
+
+	if c.refresh <= 0 || i.Expires.IsZero() {
+		return false
+	}
+	if _, ok := p.(*TypedNoExpirationPolicy[K, V]); ok {
+		return false
+	}
+
+	return i.Expires.Sub(c.clock.Now()) <= c.refresh
 }
 
-// ExpirationPolicy represents a cache item expiration policy
-type ExpirationPolicy interface {
-	Apply(*Item) error
+// refreshItem invokes the request create func and replaces the cached item with the
+// result. The existing item is retained if the create func returns an error, or a nil
+// value if SkipNilValues is set. The result is discarded if the item was removed or
+// replaced while the create func was invoked.
+func (c *TypedCache[K, V]) refreshItem(ctx context.Context, r TypedGetOrAdd[K, V], cl *call[V]) {
+	defer close(cl.done)
+
+	ttl := c.invoke(ctx, &r, cl)
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	// the call is replaced if a forced request was made for the key
+	if c.calls[r.Key] == cl {
+		delete(c.calls, r.Key)
+	}
+
+	if cl.err != nil || c.closed || cl.superseded || (c.skipNil && isNil(cl.val)) {
+		return
+	}
+	if _, ok := c.items[r.Key]; !ok {
+		return
+	}
+
+	c.set(r.Key, cl.val, ttl, r.Weight, r.Policy, r.Tags).CreateDuration = cl.elapsed
 }
 
-// NewNoExpirationPolicy returns a new NoExpirationPolicy
-func NewNoExpirationPolicy() *NoExpirationPolicy {
-	return new(NoExpirationPolicy)
+// Set adds the value to the cache with the specified key and TTL.
+// If the key already exists then the value and expiry are replaced, ItemEvicted is invoked
+// for the previous item with EvictReplaced and the previous value is returned with true.
+// If the previous item had expired then ItemEvicted is invoked with EvictExpired instead
+// and the zero value is returned with false. The item has a weight of one. The value is
+// not replaced by the result of a background refresh that is in-flight for the key.
+// Set is a no-op if the cache has been closed.
+func (c *TypedCache[K, V]) Set(key K, value V, ttl time.Duration) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var old V
+	if c.closed || c.rejected(key) {
+		return old, false
+	}
+
+	var existed bool
+	if i, ok := c.items[key]; ok && c.unexpired(i) && i.err == nil {
+		old, existed = i.Value, true
+	}
+
+	c.supersede(key)
+	c.set(key, value, ttl, 1, nil, nil)
+	return old, existed
 }
 
-// NoExpirationPolicy represents a non-expiring expiration policy
-type NoExpirationPolicy struct {
+// SetMulti adds the item keys and values to the cache with the specified TTL under a
+// single lock acquisition, or one per batch if Options.BatchSize is set. The item expiry
+// is ignored. Items are added in order, so if the batch exceeds the cache capacity then
+// earlier items are evicted to make room for later items and the last items in the batch
+// are retained. SetMulti is a no-op if the cache has been closed.
+func (c *TypedCache[K, V]) SetMulti(items []TypedItem[K, V], ttl time.Duration) {
+	c.batched(len(items), func(start, end int) bool {
+		c.mu.Lock()
+		defer c.unlock()
+
+		if c.closed {
+			return false
+		}
+
+		for idx := start; idx < end; idx++ {
+			if !c.rejected(items[idx].Key) {
+				c.supersede(items[idx].Key)
+				c.set(items[idx].Key, items[idx].Value, ttl, 1, nil, nil)
+			}
+		}
+
+		return true
+	})
 }
 
-// Apply is a no-op as the policy does not allow items to expire
-func (p *NoExpirationPolicy) Apply(i *Item) error {
-	return nil
+// lockWait acquires the lock and returns the time spent waiting for it if OnLockWait
+// is set and the wait exceeded LockWaitThreshold, otherwise zero. The time is only
+// measured if the lock is contended.
+func (c *TypedCache[K, V]) lockWait() time.Duration {
+	if c.OnLockWait == nil {
+		c.mu.Lock()
+		return 0
+	}
+	if c.mu.TryLock() {
+		return 0
+	}
+
+	start := time.Now()
+	c.mu.Lock()
+
+	if d := time.Since(start); d > c.lockWaitThreshold {
+		return d
+	}
+
+	return 0
 }
 
-// NewFixedExpirationPolicy returns a new FixedExpirationPolicy
-func NewFixedExpirationPolicy() *FixedExpirationPolicy {
-	return new(FixedExpirationPolicy)
+// rejected returns true if the key is empty and empty keys are rejected
+func (c *TypedCache[K, V]) rejected(key K) bool {
+	var zero K
+	return c.rejectEmpty && key == zero
 }
 
-// FixedExpirationPolicy represents a fixed expiration policy
-type FixedExpirationPolicy struct {
+// batched invokes the func with consecutive ranges of up to Options.BatchSize of the n
+// items until it returns false, yielding the processor between ranges so that other
+// goroutines can acquire the lock. If the batch size is not set then the func is invoked
+// once with all items. The func is invoked at least once, even if there are no items.
+func (c *TypedCache[K, V]) batched(n int, fn func(start, end int) bool) {
+	size := c.batchSize
+	if size < 1 {
+		size = n
+	}
+
+	for start := 0; start == 0 || start < n; start += size {
+		if start > 0 {
+			runtime.Gosched()
+		}
+		if !fn(start, min(start+size, n)) {
+			return
+		}
+	}
 }
 
-// Apply returns an error if the item has expired. The item expiry will not be updated.
-func (p *FixedExpirationPolicy) Apply(i *Item) error {
-	now := UTCNow()
+// UpdateValue replaces the value of the item with the specified key without updating
+// the item expiry and returns true if the key exists and the item has not expired. The
+// update is recorded as an access by the eviction policy, which otherwise retains the
+// item state. ItemEvicted is invoked with a copy of the previous item and EvictReplaced.
+func (c *TypedCache[K, V]) UpdateValue(key K, value V) bool {
+	c.mu.Lock()
+	defer c.unlock()
 
-	if i.Expires.Before(now) || i.Expires.Equal(now) {
-		return errors.New("item has expired")
+	i, ok := c.live(key)
+	if !ok {
+		return false
 	}
 
-	return nil
+	c.update(i, value)
+	return true
 }
 
-// NewSlidingExpirationPolicy returns a new SlidingExpirationPolicy with
-// the specified TTL
-func NewSlidingExpirationPolicy(ttl time.Duration) *SlidingExpirationPolicy {
-	return &SlidingExpirationPolicy{ttl: ttl}
+// CompareAndSwap replaces the value of the item with the specified key if the current
+// value is equal to old and returns true if the value was replaced. Values are compared
+// using Options.Equal if set, otherwise using ==, in which case values that are not
+// comparable are never equal. As with UpdateValue, the item expiry is not updated.
+func (c *TypedCache[K, V]) CompareAndSwap(key K, old, new V) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.live(key)
+	if !ok || !c.equal(i.Value, old) {
+		return false
+	}
+
+	c.update(i, new)
+	return true
 }
 
-// SlidingExpirationPolicy represents a sliding expiration policy
-type SlidingExpirationPolicy struct {
-	ttl time.Duration
+// live returns the non-expired item with the specified key without updating the item
+// expiry, removing the item if it has expired. The caller must hold the lock.
+func (c *TypedCache[K, V]) live(key K) (*TypedItem[K, V], bool) {
+	i, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !c.unexpired(i) {
+		c.remove(i, EvictExpired)
+		return nil, false
+	}
+	if i.err != nil {
+		return nil, false
+	}
+
+	return i, true
 }
 
-// Apply resets the TTL for the specified item. An error will be returned if
-// the item has expired and cannot be refreshed.
-func (p *SlidingExpirationPolicy) Apply(i *Item) error {
-	now := UTCNow()
+// update replaces the item value in place, so that its eviction state is retained, and
+// records an access without updating the item expiry. Other items are evicted if the new
+// value exceeds MaxBytes, after which ItemEvicted is invoked with a copy of the previous
+// item. The caller must hold the lock.
+func (c *TypedCache[K, V]) update(i *TypedItem[K, V], value V) {
+	c.supersede(i.Key)
+	prev := *i
+
+	i.Value = value
+	c.bytes -= i.size
+	i.size = c.sizer(value)
+	c.bytes += i.size
+	c.eviction.RecordAccess(i)
+
+	// the item is pinned so that it is not evicted to make room for itself
+	i.pinned = true
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		if c.removeExpiredVictim() {
+			continue
+		}
+
+		e := c.evict()
+		if e == nil {
+			break
+		}
 
-	if i.Expires.Before(now) || i.Expires.Equal(now) {
-		return errors.New("item has expired")
+		c.evicted(e, EvictCapacity)
 	}
+	i.pinned = prev.pinned
 
-	i.Expires = now.Add(p.ttl)
-	return nil
+	c.evicted(&prev, EvictReplaced)
+}
+
+// SetPolicy replaces the cache expiration policy. If nil then NoExpirationPolicy is used.
+// Existing items retain their expiry, which is applied by the new policy from the next
+// access. For example, an item that had a sliding expiry expires at its most recently
+// extended expiry under a fixed policy, whereas an item with a fixed expiry is extended
+// on each access under a sliding policy. The policy is not initialized for existing
+// items and items with their own policy are not affected.
+func (c *TypedCache[K, V]) SetPolicy(p TypedExpirationPolicy[K, V]) {
+	if p == nil {
+		p = NewTypedNoExpirationPolicy[K, V]()
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.policy = p
+	c.shared.Store(c.sharedAccess())
+}
+
+// GetStale returns the value of the item with the specified key, true if the item has
+// expired and true if the key exists. Expired items are not removed, so that stale values
+// can be served if a new value cannot be created, but they may already have been removed
+// by another operation. The item recency and expiry are not updated.
+func (c *TypedCache[K, V]) GetStale(key K) (value V, stale bool, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.items[key]
+	if !ok || i.err != nil {
+		return value, false, false
+	}
+
+	return i.Value, !c.unexpired(i), true
+}
+
+// Contains returns true if the cache contains a non-expired item with the specified key.
+// The item recency and expiry are not updated.
+func (c *TypedCache[K, V]) Contains(key K) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	return c.unexpired(i) && i.err == nil
+}
+
+// TTL returns the time remaining until the item with the specified key expires and true
+// if the key exists and the item has not expired. A zero duration is returned for items
+// that do not expire. The remaining time is calculated from the expiry as of the last
+// access, so it is not extended by a sliding expiration policy. The item recency and
+// expiry are not updated.
+func (c *TypedCache[K, V]) TTL(key K) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.live(key)
+	if !ok {
+		return 0, false
+	}
+
+	if _, ok := c.policyFor(i).(*TypedNoExpirationPolicy[K, V]); ok || i.Expires.IsZero() {
+		return 0, true
+	}
+
+	return i.Expires.Sub(c.clock.Now()), true
+}
+
+// Touch applies the expiration policy to the item with the specified key and records
+// an access without returning the value, which resets the expiry for a sliding policy.
+// It returns true if the key exists and the item has not expired.
+func (c *TypedCache[K, V]) Touch(key K) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	if err := c.apply(i); err != nil {
+		c.remove(i, EvictExpired)
+		return false
+	}
+	if i.err != nil {
+		return false
+	}
+
+	c.access(i)
+	return true
+}
+
+// Pin marks the item with the specified key as exempt from capacity eviction and returns
+// true if the key exists and the item has not expired. Negative entries cannot be pinned.
+// Pinned items are retained when the eviction policy selects them, as for CanEvict, and
+// are still removed when they expire or are explicitly removed. If all items are pinned then new items are added and the cache exceeds its capacity.
+func (c *TypedCache[K, V]) Pin(key K) bool {
+	return c.setPinned(key, true)
+}
+
+// Unpin removes the capacity eviction exemption for the item with the specified key and
+// returns true if the key exists and the item has not expired
+func (c *TypedCache[K, V]) Unpin(key K) bool {
+	return c.setPinned(key, false)
+}
+
+func (c *TypedCache[K, V]) setPinned(key K, pinned bool) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.live(key)
+	if !ok {
+		return false
+	}
+
+	i.pinned = pinned
+	return true
+}
+
+// Len returns the number of items in the cache. Items are expired lazily, so
+// the count includes expired items that have not yet been removed.
+func (c *TypedCache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// Capacity returns the effective cache capacity, including the default capacity if none
+// was specified. If the capacity is unlimited or only MaxBytes was specified then
+// math.MaxInt is returned.
+func (c *TypedCache[K, V]) Capacity() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cap
+}
+
+// Keys returns the cached keys in eviction order, which for the default policy is
+// from least to most recently used. Items are expired lazily, so the result includes
+// expired keys that have not yet been removed.
+func (c *TypedCache[K, V]) Keys() []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]K, 0, len(c.items))
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		keys = append(keys, i.Key)
+		return true
+	})
+
+	return keys
+}
+
+// Values returns the values of the non-expired items in eviction order, which for the
+// default policy is from least to most recently used. Snapshot can be used to return
+// the keys along with the values.
+func (c *TypedCache[K, V]) Values() []V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	vs := make([]V, 0, len(c.items))
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if c.unexpired(i) && i.err == nil {
+			vs = append(vs, i.Value)
+		}
+
+		return true
+	})
+
+	return vs
+}
+
+// Range invokes the func for each non-expired item in eviction order, which for the
+// default policy is from least to most recently used, until the func returns false.
+// The item recency and expiry are not updated. The lock is held for the duration of
+// the iteration, so the func must not call any cache methods.
+func (c *TypedCache[K, V]) Range(fn func(key K, value V) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if !c.unexpired(i) || i.err != nil {
+			return true
+		}
+
+		return fn(i.Key, i.Value)
+	})
+}
+
+// Snapshot returns a copy of each non-expired item in eviction order, which for the
+// default policy is from least to most recently used. Unlike Range, the lock is only
+// held while the items are copied. The values are not copied.
+func (c *TypedCache[K, V]) Snapshot() []TypedItem[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	is := make([]TypedItem[K, V], 0, len(c.items))
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if c.unexpired(i) && i.err == nil {
+			is = append(is, TypedItem[K, V]{
+				Key:        i.Key,
+				Value:      i.Value,
+				Expires:    i.Expires,
+				Created:    i.Created,
+				LastAccess: i.LastAccess,
+				Tags:       i.Tags,
+			})
+		}
+
+		return true
+	})
+
+	return is
+}
+
+// CheckInvariants returns an error if the internal cache state is inconsistent, which
+// indicates a bug in the cache or in a custom eviction policy. It verifies that the
+// eviction policy tracks each cached item exactly once, that the tracked items are
+// the items cached for their keys, that the tracked weight and size match the items,
+// that the tag index matches the item tags and that cached keys are not in the ghost
+// list. It is intended for tests and health checks and holds the read lock while it
+// visits every item.
+func (c *TypedCache[K, V]) CheckInvariants() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var n, weight int
+	var bytes int64
+	var err error
+	seen := make(map[K]struct{}, len(c.items))
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if _, ok := seen[i.Key]; ok {
+			err = fmt.Errorf("key %v is tracked more than once by the eviction policy", i.Key)
+			return false
+		}
+		if c.items[i.Key] != i {
+			err = fmt.Errorf("key %v is tracked by the eviction policy but is not cached", i.Key)
+			return false
+		}
+
+		seen[i.Key] = struct{}{}
+		n++
+		weight += i.weight
+		bytes += i.size
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if n != len(c.items) {
+		return fmt.Errorf("eviction policy tracks %d items, expected %d", n, len(c.items))
+	}
+	if weight != c.weight {
+		return fmt.Errorf("item weight is %d, expected %d", weight, c.weight)
+	}
+	if bytes != c.bytes {
+		return fmt.Errorf("item size is %d bytes, expected %d", bytes, c.bytes)
+	}
+
+	for t, ks := range c.tags {
+		for k := range ks {
+			i, ok := c.items[k]
+			if !ok || !slices.Contains(i.Tags, t) {
+				return fmt.Errorf("key %v is indexed for tag %s but does not have the tag", k, t)
+			}
+		}
+	}
+	for k, i := range c.items {
+		for _, t := range i.Tags {
+			if _, ok := c.tags[t][k]; !ok {
+				return fmt.Errorf("key %v has tag %s but is not indexed", k, t)
+			}
+		}
+	}
+
+	if c.ghosts != nil {
+		for k := range c.ghosts.elements {
+			if _, ok := c.items[k]; ok {
+				return fmt.Errorf("key %v is in the ghost list but is cached", k)
+			}
+		}
+	}
+
+	return nil
+}
+
+// String returns a summary of the cache length, capacity and expiration policy type
+func (c *TypedCache[K, V]) String() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return fmt.Sprintf("lru.Cache{len: %d, capacity: %d, policy: %T}", len(c.items), c.cap, c.policy)
+}
+
+// Dump returns the cached keys and expiry times in eviction order, one item per line,
+// for debugging. Items that do not expire are listed with a zero expiry and expired
+// items that have not yet been removed are included.
+func (c *TypedCache[K, V]) Dump() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var b strings.Builder
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		fmt.Fprintf(&b, "%v\t%s\n", i.Key, i.Expires.Format(time.RFC3339Nano))
+		return true
+	})
+
+	return b.String()
+}
+
+// Remove removes the item with the specified key from the cache and returns true
+// if it existed. ItemEvicted is invoked for the removed item with EvictRemoved.
+func (c *TypedCache[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	defer c.unlock()
+
+	i, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	c.remove(i, EvictRemoved)
+	return true
+}
+
+// Take removes the item with the specified key and returns its value and true if it
+// had not expired, in a single operation so that the value is returned to one caller.
+// ItemEvicted is invoked for the removed item with EvictRemoved, or EvictExpired if
+// the item had expired.
+func (c *TypedCache[K, V]) Take(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var v V
+	i, ok := c.items[key]
+	if !ok || i.err != nil {
+		return v, false
+	}
+
+	if !c.unexpired(i) {
+		c.remove(i, EvictExpired)
+		return v, false
+	}
+
+	// the value is returned to the caller, so it is not closed
+	c.delete(i)
+	c.notify(i, EvictRemoved, false)
+	return i.Value, true
+}
+
+// RemoveFunc removes each non-expired item for which the func returns true and returns
+// the number of items removed. ItemEvicted is invoked for each removed item with
+// EvictRemoved. The lock is held while the func is invoked, so it must not call any
+// cache methods.
+func (c *TypedCache[K, V]) RemoveFunc(fn func(key K, value V) bool) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	var is []*TypedItem[K, V]
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if c.unexpired(i) && i.err == nil && fn(i.Key, i.Value) {
+			is = append(is, i)
+		}
+
+		return true
+	})
+
+	for _, i := range is {
+		c.delete(i)
+	}
+
+	c.evictedAll(is, EvictRemoved)
+	return len(is)
+}
+
+// InvalidateTag removes all items with the specified tag and returns the number of items
+// removed. ItemEvicted is invoked for each removed item with EvictRemoved.
+func (c *TypedCache[K, V]) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	ks := c.tags[tag]
+	is := make([]*TypedItem[K, V], 0, len(ks))
+	for k := range ks {
+		is = append(is, c.items[k])
+	}
+
+	for _, i := range is {
+		c.delete(i)
+	}
+
+	c.evictedAll(is, EvictRemoved)
+	return len(is)
+}
+
+// RemoveExpired removes all expired items without updating the expiry of live items
+// and returns the number of items removed. ItemsEvicted is invoked with the removed
+// items and EvictExpired, or ItemEvicted for each removed item if ItemsEvicted is nil.
+func (c *TypedCache[K, V]) RemoveExpired() int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	return c.removeExpired()
+}
+
+// Clear removes all items from the cache. ItemEvicted is invoked for each
+// removed item in eviction order with EvictRemoved.
+func (c *TypedCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.clear()
+}
+
+// Close removes all items from the cache, stops any background goroutines and marks
+// the cache as closed. ItemEvicted is invoked for each removed item with EvictRemoved.
+// Subsequent GetOrAdd requests return ErrClosed and other operations are no-ops.
+// Requests waiting on an in-flight create func still receive its result, but the
+// result is not cached.
+func (c *TypedCache[K, V]) Close() error {
+	c.mu.Lock()
+
+	if c.closed {
+		c.unlock()
+		return ErrClosed
+	}
+
+	c.closed = true
+	c.clear()
+	c.unlock()
+
+	if c.stop != nil {
+		close(c.stop)
+		<-c.stopped
+	}
+
+	return nil
+}
+
+// Resize sets the cache capacity and returns the number of items evicted to fit within
+// it. Items are evicted in eviction order and ItemEvicted is invoked with EvictCapacity.
+// If the capacity is zero then the default capacity is used and if it is negative then
+// the capacity is unlimited, as with NewCache.
+func (c *TypedCache[K, V]) Resize(capacity int) int {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.cap = capacityOrDefault(capacity, c.maxBytes)
+
+	var is []*TypedItem[K, V]
+	for c.weight > c.cap {
+		i := c.evict()
+		if i == nil {
+			break
+		}
+
+		is = append(is, i)
+	}
+
+	c.evictedAll(is, EvictCapacity)
+	return len(is)
+}
+
+// reap removes expired items at the specified interval until the cache is closed
+func (c *TypedCache[K, V]) reap(interval time.Duration) {
+	defer close(c.stopped)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			c.mu.Lock()
+			c.removeExpired()
+			c.unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// removeExpired removes all expired items without updating the expiry of live items
+// and returns the number of items removed. The caller must hold the lock.
+func (c *TypedCache[K, V]) removeExpired() int {
+	var is []*TypedItem[K, V]
+	for _, i := range c.items {
+		if !c.unexpired(i) {
+			c.delete(i)
+			is = append(is, i)
+		}
+	}
+
+	c.evictedAll(is, EvictExpired)
+	return len(is)
+}
+
+// clear removes all items from the cache.
+// The caller must hold the lock.
+func (c *TypedCache[K, V]) clear() {
+	ev := c.eviction
+
+	c.items = make(map[K]*TypedItem[K, V], c.initialCap)
+	c.tags = map[string]map[K]struct{}{}
+	c.bytes = 0
+	c.weight = 0
+	c.itemPolicies = 0
+	c.eviction = c.newEviction()
+
+	var is []*TypedItem[K, V]
+	ev.Range(func(i *TypedItem[K, V]) bool {
+		c.unschedule(i)
+		is = append(is, i)
+		return true
+	})
+
+	c.evictedAll(is, EvictRemoved)
+}
+
+// getShared returns the value for a live item under the read lock
+func (c *TypedCache[K, V]) getShared(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var v V
+
+	// the policy may have been replaced since shared access was checked
+	if !c.sharedAccess() {
+		return v, false
+	}
+
+	// items with their own policy are not served as the policy may update the item
+	if i, ok := c.items[key]; ok && i.policy == nil {
+		if err := c.apply(i); err == nil && i.err == nil {
+			c.eviction.RecordAccess(i)
+			c.stats.hits.Add(1)
+
+			return i.Value, true
+		}
+	}
+
+	return v, false
+}
+
+// sharedAccess returns true if hits can be served under the read lock. This requires
+// an expiration policy that does not update items and an eviction policy that can
+// record accesses concurrently.
+func (c *TypedCache[K, V]) sharedAccess() bool {
+	if c.refresh > 0 {
+		return false
+	}
+
+	switch c.eviction.(type) {
+	case *TypedApproximateLRUEvictionPolicy[K, V], *TypedFIFOEvictionPolicy[K, V]:
+	default:
+		return false
+	}
+
+	switch c.policy.(type) {
+	case *TypedNoExpirationPolicy[K, V], *TypedFixedExpirationPolicy[K, V]:
+		return true
+	default:
+		return false
+	}
+}
+
+// set adds or replaces the item with the specified key. If the item is replaced then
+// ItemEvicted is invoked with a copy of the previous item and EvictReplaced, unless the
+// previous item has expired, in which case it is removed with EvictExpired.
+// The caller must hold the lock.
+func (c *TypedCache[K, V]) set(key K, value V, ttl time.Duration, weight int, policy TypedExpirationPolicy[K, V], tags []string) *TypedItem[K, V] {
+	if len(tags) > 0 {
+		tags = append([]string(nil), tags...)
+	}
+
+	if i, ok := c.items[key]; ok && !c.unexpired(i) {
+		c.remove(i, EvictExpired)
+	}
+
+	if i, ok := c.items[key]; ok {
+		prev := *i
+
+		// detach the item so that it cannot be evicted to make room for itself
+		c.delete(i)
+
+		i.Value = value
+		i.weight = weight
+		i.policy = policy
+		i.Tags = tags
+		i.CreateDuration = 0
+		i.err = nil
+		c.init(i, ttl)
+		c.insert(i)
+
+		c.evicted(&prev, EvictReplaced)
+		return i
+	}
+
+	return c.add(key, value, ttl, weight, policy, tags)
+}
+
+// add inserts a new item and invokes ItemAdded. The caller must hold the lock.
+func (c *TypedCache[K, V]) add(key K, value V, ttl time.Duration, weight int, policy TypedExpirationPolicy[K, V], tags []string) *TypedItem[K, V] {
+	i := &TypedItem[K, V]{
+		Key:    key,
+		Value:  value,
+		Tags:   tags,
+		weight: weight,
+		policy: policy,
+	}
+	c.init(i, ttl)
+	c.insert(i)
+
+	c.ItemAdded(i)
+	return i
+}
+
+// addNegative inserts a negative entry for the create func error that expires
+// after the negative TTL. The caller must hold the lock.
+func (c *TypedCache[K, V]) addNegative(key K, err error) {
+	c.insert(&TypedItem[K, V]{
+		Key:     key,
+		Expires: c.clock.Now().Add(c.negativeTTL),
+		err:     err,
+	})
+}
+
+// insert adds the item to the cache, evicting items selected by the eviction policy
+// until the capacity and size limits allow it to be added. An item that exceeds
+// either limit is added once all other items that can be evicted have been evicted.
+// The caller must hold the lock.
+func (c *TypedCache[K, V]) insert(i *TypedItem[K, V]) {
+	if i.weight < 1 {
+		i.weight = 1
+	}
+	if i.err == nil {
+		i.size = c.sizer(i.Value)
+	}
+
+	for len(c.items) > 0 && (c.weight+i.weight > c.cap || (c.maxBytes > 0 && c.bytes+i.size > c.maxBytes)) {
+		if c.removeExpiredVictim() {
+			continue
+		}
+
+		e := c.evict()
+		if e == nil {
+			break
+		}
+
+		c.evicted(e, EvictCapacity)
+	}
+
+	c.items[i.Key] = i
+	c.bytes += i.size
+	c.weight += i.weight
+	if i.policy != nil {
+		c.itemPolicies++
+	}
+	c.eviction.Add(i)
+	if c.ghosts != nil {
+		c.ghosts.remove(i.Key)
+	}
+	c.schedule(i)
+
+	for _, t := range i.Tags {
+		ks, ok := c.tags[t]
+		if !ok {
+			ks = map[K]struct{}{}
+			c.tags[t] = ks
+		}
+
+		ks[i.Key] = struct{}{}
+	}
+}
+
+// removeExpiredVictim removes the first expired item of up to expiredVictimScan items in
+// eviction order and returns true if an item was removed. This avoids evicting live
+// items to make room while expired items are cached. Policies that sort the items to
+// range over them are not scanned, nor are caches in which no item can expire. The caller
+// must hold the lock.
+func (c *TypedCache[K, V]) removeExpiredVictim() bool {
+	switch c.eviction.(type) {
+	case *TypedLFUEvictionPolicy[K, V], *TypedSampledEvictionPolicy[K, V]:
+		return false
+	}
+	if _, ok := c.policy.(*TypedNoExpirationPolicy[K, V]); ok && c.negativeTTL <= 0 && c.itemPolicies == 0 {
+		return false
+	}
+
+	var victim *TypedItem[K, V]
+	var n int
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if !c.unexpired(i) {
+			victim = i
+			return false
+		}
+
+		n++
+		return n < expiredVictimScan
+	})
+
+	if victim == nil {
+		return false
+	}
+
+	c.remove(victim, EvictExpired)
+	return true
+}
+
+// schedule starts an expiry timer for the item if ExpiryTimers is set
+func (c *TypedCache[K, V]) schedule(i *TypedItem[K, V]) {
+	c.unschedule(i)
+
+	if !c.timers || i.err != nil || i.Expires.IsZero() {
+		return
+	}
+	if _, ok := c.policyFor(i).(*TypedNoExpirationPolicy[K, V]); ok {
+		return
+	}
+
+	i.timer = time.AfterFunc(i.Expires.Sub(c.clock.Now()), func() {
+		c.expire(i)
+	})
+}
+
+// unschedule stops the item expiry timer if it exists
+func (c *TypedCache[K, V]) unschedule(i *TypedItem[K, V]) {
+	if i.timer != nil {
+		i.timer.Stop()
+		i.timer = nil
+	}
+}
+
+// expire removes the item if it has expired, otherwise the expiry timer is rescheduled
+func (c *TypedCache[K, V]) expire(i *TypedItem[K, V]) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	// the item may have been removed after the timer fired
+	if c.items[i.Key] != i {
+		return
+	}
+
+	if !c.unexpired(i) {
+		c.remove(i, EvictExpired)
+		return
+	}
+
+	if i.Expires.After(c.clock.Now()) {
+		c.schedule(i)
+	}
+}
+
+// evict removes and returns the item selected by the eviction policy, or nil if there
+// are no items that can be evicted. Pinned items and items for which CanEvict returns
+// false are skipped without affecting their eviction state, unless the policy is not a
+// built-in policy, in which case they are added back to it. The caller must hold the
+// lock and notify ItemEvicted with EvictCapacity.
+func (c *TypedCache[K, V]) evict() *TypedItem[K, V] {
+	evictable := func(i *TypedItem[K, V]) bool {
+		return !i.pinned && c.CanEvict(i)
+	}
+
+	var i *TypedItem[K, V]
+	if p, ok := c.eviction.(skippingEvictionPolicy[K, V]); ok {
+		i = p.evictFunc(evictable)
+	} else {
+		i = c.evictAdding(evictable)
+	}
+	if i == nil {
+		return nil
+	}
+
+	delete(c.items, i.Key)
+	c.bytes -= i.size
+	c.weight -= i.weight
+	if i.policy != nil {
+		c.itemPolicies--
+	}
+	c.unschedule(i)
+	c.untag(i)
+
+	// negative entries are not counted as evictions, as they do not cache a value
+	if i.err == nil {
+		c.stats.recordEviction(c.clock.Now().Sub(i.Created))
+		if c.ghosts != nil {
+			c.ghosts.add(i.Key, c.ghostSize)
+		}
+	}
+
+	return i
+}
+
+// evictAdding evicts items from a policy that cannot skip items until the func returns
+// true, and then adds the skipped items back to the policy
+func (c *TypedCache[K, V]) evictAdding(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	var skipped []*TypedItem[K, V]
+	defer func() {
+		for _, s := range skipped {
+			c.eviction.Add(s)
+		}
+	}()
+
+	for {
+		i := c.eviction.Evict()
+		if i == nil || fn(i) {
+			return i
+		}
+
+		skipped = append(skipped, i)
+	}
+}
+
+// apply applies the expiration policy to the item using the cache clock if
+// supported by the policy
+func (c *TypedCache[K, V]) apply(i *TypedItem[K, V]) error {
+	if i.err != nil {
+		// negative entries expire regardless of the policy
+		if !c.clock.Now().Before(i.Expires) {
+			return ErrExpired
+		}
+
+		return nil
+	}
+
+	pol := c.policyFor(i)
+	if p, ok := pol.(TypedClockExpirationPolicy[K, V]); ok {
+		return p.ApplyAt(i, c.clock.Now())
+	}
+
+	return pol.Apply(i)
+}
+
+// unexpired returns true if the expiration policy does not expire the item. The policy
+// is applied to a copy of the fields that it may read or update, so that the expiry is
+// not extended and the fields that are updated under the read lock are not read.
+func (c *TypedCache[K, V]) unexpired(i *TypedItem[K, V]) bool {
+	cp := TypedItem[K, V]{
+		Key:            i.Key,
+		Value:          i.Value,
+		Expires:        i.Expires,
+		Created:        i.Created,
+		LastAccess:     i.LastAccess,
+		CreateDuration: i.CreateDuration,
+		err:            i.err,
+		policy:         i.policy,
+	}
+
+	return c.apply(&cp) == nil
+}
+
+// access records an access for the item with the eviction policy and sets the
+// item access time. The caller must hold the lock.
+func (c *TypedCache[K, V]) access(i *TypedItem[K, V]) {
+	c.eviction.RecordAccess(i)
+	i.LastAccess = c.clock.Now()
+}
+
+// policyFor returns the item expiration policy if set, otherwise the cache policy
+func (c *TypedCache[K, V]) policyFor(i *TypedItem[K, V]) TypedExpirationPolicy[K, V] {
+	if i.policy != nil {
+		return i.policy
+	}
+
+	return c.policy
+}
+
+// init sets the item timestamps and the expiry using the specified TTL and
+// initialises the item if supported by the policy. A zero TTL results in a zero expiry, which the
+// expiration policies treat as never expiring.
+func (c *TypedCache[K, V]) init(i *TypedItem[K, V], ttl time.Duration) {
+	now := c.clock.Now()
+
+	i.Created = now
+	i.LastAccess = now
+
+	if ttl == 0 {
+		i.Expires = time.Time{}
+	} else {
+		i.Expires = now.Add(ttl)
+	}
+
+	switch p := c.policyFor(i).(type) {
+	case randomItemInitializer[K, V]:
+		p.initRand(i, c.rand)
+	case TypedItemInitializer[K, V]:
+		p.Init(i)
+	}
+}
+
+// remove removes the item from the cache and invokes ItemEvicted with the reason.
+// The caller must hold the lock.
+func (c *TypedCache[K, V]) remove(i *TypedItem[K, V], reason EvictReason) {
+	c.delete(i)
+	c.evicted(i, reason)
+}
+
+// evicted invokes ItemEvicted with the reason unless the item is a negative entry
+// and closes the item value if Options.AutoCloseValues is set
+func (c *TypedCache[K, V]) evicted(i *TypedItem[K, V], reason EvictReason) {
+	c.notify(i, reason, c.autoClose)
+}
+
+// notify invokes ItemEvicted with the reason unless the item is a negative entry and
+// closes the item value if specified
+func (c *TypedCache[K, V]) notify(i *TypedItem[K, V], reason EvictReason, close bool) {
+	if i.err != nil {
+		return
+	}
+
+	var cl []*TypedItem[K, V]
+	if close {
+		cl = c.closable([]*TypedItem[K, V]{i})
+	}
+
+	if c.unlocked {
+		c.pending = append(c.pending, eviction[K, V]{items: []*TypedItem[K, V]{i}, reason: reason, closes: cl})
+		return
+	}
+
+	c.ItemEvicted(i, reason)
+	c.closeValues(cl)
+}
+
+// evictedAll invokes ItemsEvicted with the items if it is set, otherwise ItemEvicted
+// is invoked for each item. Negative entries are excluded.
+func (c *TypedCache[K, V]) evictedAll(is []*TypedItem[K, V], reason EvictReason) {
+	if c.ItemsEvicted == nil {
+		for _, i := range is {
+			c.evicted(i, reason)
+		}
+
+		return
+	}
+
+	b := make([]*TypedItem[K, V], 0, len(is))
+	for _, i := range is {
+		if i.err == nil {
+			b = append(b, i)
+		}
+	}
+
+	if len(b) < 1 {
+		return
+	}
+
+	var cl []*TypedItem[K, V]
+	if c.autoClose {
+		cl = c.closable(b)
+	}
+
+	if c.unlocked {
+		c.pending = append(c.pending, eviction[K, V]{items: b, reason: reason, batch: true, closes: cl})
+		return
+	}
+
+	c.ItemsEvicted(b, reason)
+	c.closeValues(cl)
+}
+
+// closable returns the items with values that implement io.Closer, excluding values
+// that are still cached for the item key. The caller must hold the lock.
+func (c *TypedCache[K, V]) closable(is []*TypedItem[K, V]) []*TypedItem[K, V] {
+	var res []*TypedItem[K, V]
+	for _, i := range is {
+		v, ok := any(i.Value).(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if ci, ok := c.items[i.Key]; ok {
+			if cv, ok := any(ci.Value).(io.Closer); ok && reflect.ValueOf(v).Comparable() && reflect.ValueOf(cv).Comparable() && v == cv {
+				continue
+			}
+		}
+
+		res = append(res, i)
+	}
+
+	return res
+}
+
+// closeValues closes the item values and invokes OnCloseError for each error
+func (c *TypedCache[K, V]) closeValues(is []*TypedItem[K, V]) {
+	for _, i := range is {
+		if err := any(i.Value).(io.Closer).Close(); err != nil {
+			c.OnCloseError(i.Key, err)
+		}
+	}
+}
+
+// unlock releases the lock and invokes the deferred ItemEvicted and ItemsEvicted funcs,
+// closing the removed values if Options.AutoCloseValues is set
+func (c *TypedCache[K, V]) unlock() {
+	p := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	for _, e := range p {
+		if e.batch {
+			c.ItemsEvicted(e.items, e.reason)
+		} else {
+			c.ItemEvicted(e.items[0], e.reason)
+		}
+
+		c.closeValues(e.closes)
+	}
+}
+
+// delete removes the item from the cache without invoking ItemEvicted.
+// The caller must hold the lock.
+func (c *TypedCache[K, V]) delete(i *TypedItem[K, V]) {
+	c.unschedule(i)
+	c.eviction.Remove(i)
+	delete(c.items, i.Key)
+	c.bytes -= i.size
+	c.weight -= i.weight
+	if i.policy != nil {
+		c.itemPolicies--
+	}
+	c.untag(i)
+}
+
+// untag removes the item from the tag index. The caller must hold the lock.
+func (c *TypedCache[K, V]) untag(i *TypedItem[K, V]) {
+	for _, t := range i.Tags {
+		if ks, ok := c.tags[t]; ok {
+			delete(ks, i.Key)
+			if len(ks) < 1 {
+				delete(c.tags, t)
+			}
+		}
+	}
+}
+
+// capacityOrDefault returns the capacity, or the default capacity if it is zero.
+// The capacity is not limited if it is negative or only maxBytes is positive.
+func capacityOrDefault(capacity int, maxBytes int64) int {
+	if capacity > 0 {
+		return capacity
+	}
+	if capacity < 0 || maxBytes > 0 {
+		return math.MaxInt
+	}
+
+	return 100
+}
+
+// equal returns true if the values are comparable and equal
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.ValueOf(a).Comparable() || !reflect.ValueOf(b).Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// isNil returns true if the value is nil or a nil pointer, map, slice, chan or func
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// EvictReason represents the reason an item was removed from the cache
+type EvictReason int
+
+const (
+	// EvictCapacity indicates that the item was evicted to make room for a new item
+	EvictCapacity EvictReason = iota
+
+	// EvictExpired indicates that the item was removed because it had expired
+	EvictExpired
+
+	// EvictRemoved indicates that the item was explicitly removed
+	EvictRemoved
+
+	// EvictReplaced indicates that the item value was replaced
+	EvictReplaced
+)
+
+// String returns the eviction reason name
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// TypedGetOrAdd represents a typed cache GetOrAdd request
+type TypedGetOrAdd[K comparable, V any] struct {
+	// Key is the cache key. An empty key is cached as any other key unless
+	// Options.RejectEmptyKeys is set.
+	Key K
+
+	// TTL is the duration after which the created item expires, as determined by the
+	// expiration policy. If zero then the item does not expire.
+	TTL time.Duration
+
+	// Create is invoked to create the value if the key is not cached. If no create
+	// func is set then Options.Loader is used.
+	Create func() (V, error)
+
+	// CreateContext is invoked instead of Create if set. The context is derived from
+	// the context passed to GetOrAddContext, as described by GetOrAddContext.
+	CreateContext func(context.Context) (V, error)
+
+	// CreateWithTTL is invoked instead of Create and CreateContext if set. The returned TTL is used
+	// for the created item in place of the request TTL.
+	CreateWithTTL func() (V, time.Duration, error)
+
+	Result V
+
+	// Created is set by GetOrAdd to true if the request create func was invoked, or
+	// false if the result was served from the cache or by another request.
+	Created bool
+
+	// Weight is the capacity used by the created item. If zero then the item
+	// has a weight of one.
+	Weight int
+
+	// Policy is the expiration policy for the created item. If nil then the
+	// cache policy is used.
+	Policy TypedExpirationPolicy[K, V]
+
+	// Tags are stored on the created item, which can then be removed with InvalidateTag
+	Tags []string
+
+	// Force invokes the create func even if the key is cached and replaces the cached item
+	// with the result, for example after the value has been updated in the backing store.
+	// The request does not wait for an in-flight create func or refresh for the key, as it
+	// may have started before the update, and its result is discarded. The existing item is
+	// served to other requests until it is replaced and is retained if the create func
+	// returns an error.
+	Force bool
+
+	// once pins the created item and disables negative caching, as for GetOrAddOnce
+	once bool
+}
+
+// create invokes the create func and returns the value and TTL. The loader is
+// invoked if the request does not have a create func.
+func (r *TypedGetOrAdd[K, V]) create(ctx context.Context, loader func(K) (V, error)) (V, time.Duration, error) {
+	if r.CreateWithTTL != nil {
+		return r.CreateWithTTL()
+	}
+	if r.CreateContext != nil {
+		v, err := r.CreateContext(ctx)
+		return v, r.TTL, err
+	}
+	if r.Create == nil && loader != nil {
+		v, err := loader(r.Key)
+		return v, r.TTL, err
+	}
+
+	v, err := r.Create()
+	return v, r.TTL, err
+}
+
+// hasCreate returns true if the request has a create func
+func (r *TypedGetOrAdd[K, V]) hasCreate() bool {
+	return r.Create != nil || r.CreateContext != nil || r.CreateWithTTL != nil
+}
+
+// TypedItem represents a typed cached value
+type TypedItem[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Expires time.Time
+
+	// Created is the time that the item was added or replaced
+	Created time.Time
+
+	// LastAccess is the time that the item was last accessed. Hits that are served
+	// under the read lock by ApproximateLRUEvictionPolicy or FIFOEvictionPolicy do not
+	// update the time.
+	LastAccess time.Time
+
+	// Tags are the tags of the GetOrAdd request that created the item
+	Tags []string
+
+	// CreateDuration is the time taken by the create func that created the item, or zero
+	// if the item was added by Set
+	CreateDuration time.Duration
+
+	size     int64
+	weight   int
+	err      error
+	policy   TypedExpirationPolicy[K, V]
+	pinned   bool
+	element  *list.Element
+	accessed int32
+	timer    *time.Timer
+}
+
+// eviction represents a deferred ItemEvicted or ItemsEvicted invocation
+type eviction[K comparable, V any] struct {
+	items  []*TypedItem[K, V]
+	reason EvictReason
+	batch  bool
+	closes []*TypedItem[K, V]
+}
+
+// call represents an in-flight create func invocation
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	elapsed time.Duration
+
+	// panic is the value of a create func panic that is not recovered, which is set before
+	// done is closed
+	panic interface{}
+
+	// superseded is set under the cache lock if a newer value is cached or a forced create
+	// func is started for the key while the call is in-flight, in which case the call
+	// result is not cached
+	superseded bool
+
+	// ctx is the create func context for CreateContext requests, which is cancelled
+	// when no requests are waiting for the call
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+	mu      sync.Mutex
+}
+
+// supersede marks the in-flight call for the key as superseded by a newer value, so
+// that the call result is not cached in its place. The caller must hold the lock.
+func (c *TypedCache[K, V]) supersede(key K) {
+	if cl, ok := c.calls[key]; ok {
+		cl.superseded = true
+	}
+}
+
+// join registers a request waiting for the call and returns false if the call
+// context has been cancelled. It is a no-op if the call does not have a context.
+func (cl *call[V]) join() bool {
+	if cl.cancel == nil {
+		return true
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.ctx.Err() != nil {
+		return false
+	}
+
+	cl.waiters++
+	return true
+}
+
+// leave deregisters a request waiting for the call and cancels the call context if
+// no requests remain. It is a no-op if the call does not have a context.
+func (cl *call[V]) leave() {
+	if cl.cancel == nil {
+		return
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.waiters--
+	if cl.waiters == 0 {
+		cl.cancel()
+	}
+}
+
+// wait blocks until the call completes, the context is cancelled or the timeout
+// elapses. A zero timeout does not elapse.
+// panicked returns the value of a create func panic that was not recovered, or nil if
+// the call has not completed
+func (cl *call[V]) panicked() interface{} {
+	select {
+	case <-cl.done:
+		return cl.panic
+	default:
+		return nil
+	}
+}
+
+func (cl *call[V]) wait(ctx context.Context, timeout time.Duration) (V, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+
+		expired = t.C
+	}
+
+	var v V
+	select {
+	case <-cl.done:
+		return cl.val, cl.err
+	case <-ctx.Done():
+		return v, ctx.Err()
+	case <-expired:
+		return v, ErrCreateTimeout
+	}
 }
@@ -1,9 +1,16 @@
 package lru_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,8 +26,8 @@ func ExampleGetOrAdd() {
 	r := lru.GetOrAdd{
 		Key: "key",
 		TTL: 1 * time.Minute,
-		Create: func() interface{} {
-			return "value"
+		Create: func() (interface{}, error) {
+			return "value", nil
 		},
 	}
 
@@ -78,7 +85,7 @@ func TestCache(t *testing.T) {
 			Capacity: tt.capacity,
 		})
 
-		c.ItemEvicted = func(*lru.Item) {
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
 			evictions++
 		}
 
@@ -86,9 +93,9 @@ func TestCache(t *testing.T) {
 			item := tt.items[idx%len(tt.items)]
 			req := lru.GetOrAdd{
 				Key: item.Key,
-				Create: func() interface{} {
+				Create: func() (interface{}, error) {
 					invocations++
-					return item.Value
+					return item.Value, nil
 				},
 			}
 
@@ -120,9 +127,9 @@ func TestCacheWithExpiration(t *testing.T) {
 	req := lru.GetOrAdd{
 		Key: "key",
 		TTL: 1 * time.Minute,
-		Create: func() interface{} {
+		Create: func() (interface{}, error) {
 			invocations++
-			return "value"
+			return "value", nil
 		},
 	}
 
@@ -155,184 +162,4633 @@ func TestCacheWithExpiration(t *testing.T) {
 	}
 }
 
-func TestCacheParallel(t *testing.T) {
+func TestCacheWithCreateError(t *testing.T) {
+	now := time.Now().UTC()
+	evictions := 0
+	createErr := errors.New("error")
+
 	c := lru.NewCache(lru.Options{
-		Capacity: 100,
+		Capacity: 1,
+		Policy:   lru.NewFixedExpirationPolicy(),
 	})
 
-	wg := new(sync.WaitGroup)
+	c.ItemEvicted = func(_ *lru.Item, r lru.EvictReason) {
+		if r == lru.EvictCapacity {
+			evictions++
+		}
+	}
 
-	for r := 0; r < 100; r++ {
-		wg.Add(1)
-		go func(c *lru.Cache) {
-			defer wg.Done()
+	fixTime(now, func() {
+		c.Set("key_1", "value", 1*time.Minute)
+		c.Set("key_2", "value", 1*time.Minute)
+	})
+	evictions = 0
 
-			for o := 0; o < 100; o++ {
-				exp := fmt.Sprintf("value:%d", o)
+	tests := []struct {
+		key    string
+		access time.Time
+	}{
+		{key: "key_3", access: now},
+		{key: "key_2", access: now.Add(2 * time.Minute)},
+	}
 
-				req := lru.GetOrAdd{
-					Key: fmt.Sprintf("key:%d", o),
-					Create: func() interface{} {
-						return exp
-					},
-				}
+	for tn, tt := range tests {
+		fixTime(tt.access, func() {
+			req := lru.GetOrAdd{
+				Key: tt.key,
+				TTL: 1 * time.Minute,
+				Create: func() (interface{}, error) {
+					return "other", createErr
+				},
+			}
 
-				if err := c.GetOrAdd(&req); err != nil {
-					t.Errorf("GetOrAdd(); got %v, expected nil", err)
-				}
-				if req.Result != exp {
-					t.Errorf("GetOrAdd(); got %s, expected %s", req.Result, exp)
-				}
+			if err := c.GetOrAdd(&req); err != createErr {
+				t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, createErr)
 			}
-		}(c)
+			if req.Result != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, req.Result)
+			}
+			if c.Contains(tt.key) {
+				t.Errorf("Contains(%d); got true, expected false", tn)
+			}
+		})
 	}
 
-	wg.Wait()
+	if evictions != 0 {
+		t.Errorf("GetOrAdd(); got %d evictions, expected 0", evictions)
+	}
+	if act := c.Len(); act != 0 {
+		t.Errorf("Len(); got %d, expected 0", act)
+	}
 }
 
-func TestNoExpirationPolicy(t *testing.T) {
-	now := time.Now()
+func TestCacheWithNegativeTTL(t *testing.T) {
+	now := time.Now().UTC()
+	errCreate := errors.New("error")
 
 	tests := []struct {
-		expire time.Time
-		access time.Time
-		err    bool
-		exp    time.Time
+		negativeTTL time.Duration
+		access      time.Time
+		calls       int
 	}{
 		{
-			expire: now,
-			access: now.Add(1 * time.Minute),
-			err:    false,
-			exp:    now,
+			negativeTTL: 0,
+			access:      now.Add(1 * time.Second),
+			calls:       2,
 		},
 		{
-			expire: now.Add(1 * time.Minute),
-			access: now,
-			err:    false,
-			exp:    now.Add(1 * time.Minute),
+			negativeTTL: 10 * time.Second,
+			access:      now.Add(1 * time.Second),
+			calls:       1,
+		},
+		{
+			negativeTTL: 10 * time.Second,
+			access:      now.Add(10 * time.Second),
+			calls:       2,
 		},
 	}
 
 	for tn, tt := range tests {
-		fixTime(tt.access, func() {
-			i := lru.Item{Expires: tt.expire}
-			p := lru.NewNoExpirationPolicy()
+		var calls int
+		var evicted int
+
+		c := lru.NewCache(lru.Options{
+			Capacity:    2,
+			NegativeTTL: tt.negativeTTL,
+		})
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			evicted++
+		}
+
+		req := lru.GetOrAdd{
+			Key: "key",
+			TTL: 1 * time.Minute,
+			Create: func() (interface{}, error) {
+				calls++
+				return nil, errCreate
+			},
+		}
 
-			err := p.Apply(&i)
+		fixTime(now, func() {
+			if err := c.GetOrAdd(&req); err != errCreate {
+				t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, errCreate)
+			}
+		})
 
-			if err != nil && !tt.err {
-				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+		fixTime(tt.access, func() {
+			if act := c.Contains("key"); act {
+				t.Errorf("Contains(%d); got %v, expected false", tn, act)
 			}
-			if err == nil && tt.err {
-				t.Errorf("Apply(%d); got nil, expected an error", tn)
+			if err := c.GetOrAdd(&req); err != errCreate {
+				t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, errCreate)
 			}
-			if i.Expires != tt.expire {
-				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.expire)
+		})
+
+		if calls != tt.calls {
+			t.Errorf("GetOrAdd(%d); got %d calls, expected %d", tn, calls, tt.calls)
+		}
+
+		fixTime(tt.access, func() {
+			c.Set("key", "value", 1*time.Minute)
+
+			req := lru.GetOrAdd{Key: "key"}
+			if err := c.GetOrAdd(&req); err != nil || req.Result != "value" {
+				t.Errorf("GetOrAdd(%d); got %v, %v, expected value", tn, req.Result, err)
 			}
 		})
+
+		if evicted != 0 {
+			t.Errorf("ItemEvicted(%d); got %d, expected 0", tn, evicted)
+		}
 	}
 }
 
-func TestFixedExpirationPolicy(t *testing.T) {
-	now := time.Now()
+func TestCacheGetOrAddFunc(t *testing.T) {
+	createErr := errors.New("error")
 
 	tests := []struct {
-		expire time.Time
-		access time.Time
-		err    bool
-		exp    time.Time
+		key string
+		val interface{}
+		err error
+		exp interface{}
 	}{
 		{
-			expire: now,
-			access: now.Add(1 * time.Minute),
-			err:    true,
-			exp:    now,
+			key: "key",
+			val: "other",
+			exp: "value",
 		},
 		{
-			expire: now,
-			access: now,
-			err:    true,
-			exp:    now,
+			key: "other",
+			val: "other",
+			exp: "other",
 		},
 		{
-			expire: now.Add(1 * time.Minute),
-			access: now,
-			err:    false,
-			exp:    now.Add(1 * time.Minute),
+			key: "other",
+			err: createErr,
+			exp: nil,
 		},
 	}
 
 	for tn, tt := range tests {
-		fixTime(tt.access, func() {
-			i := lru.Item{Expires: tt.expire}
-			p := lru.NewFixedExpirationPolicy()
+		c := lru.NewCache(lru.Options{})
+		c.Set("key", "value", 0)
+
+		act, err := c.GetOrAddFunc(tt.key, 0, func() (interface{}, error) {
+			return tt.val, tt.err
+		})
+		if err != tt.err {
+			t.Errorf("GetOrAddFunc(%d); got %v, expected %v", tn, err, tt.err)
+		}
+		if act != tt.exp {
+			t.Errorf("GetOrAddFunc(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheGetOrAddCreated(t *testing.T) {
+	c := lru.NewCache(lru.Options{})
+	c.Set("key", "value", 0)
+
+	tests := []struct {
+		key string
+		exp bool
+	}{
+		{
+			key: "key",
+			exp: false,
+		},
+		{
+			key: "other",
+			exp: true,
+		},
+		{
+			key: "other",
+			exp: false,
+		},
+	}
+
+	req := lru.GetOrAdd{
+		Create: func() (interface{}, error) {
+			return "value", nil
+		},
+	}
+
+	for tn, tt := range tests {
+		req.Key = tt.key
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+		}
+		if req.Created != tt.exp {
+			t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, req.Created, tt.exp)
+		}
+	}
+}
+
+func TestCacheWithSkipNilValues(t *testing.T) {
+	tests := []struct {
+		skip  bool
+		value interface{}
+		calls int
+	}{
+		{
+			skip:  false,
+			value: nil,
+			calls: 1,
+		},
+		{
+			skip:  true,
+			value: nil,
+			calls: 2,
+		},
+		{
+			skip:  true,
+			value: (*string)(nil),
+			calls: 2,
+		},
+		{
+			skip:  true,
+			value: "value",
+			calls: 1,
+		},
+	}
+
+	for tn, tt := range tests {
+		var calls, evicted int
 
-			err := p.Apply(&i)
+		c := lru.NewCache(lru.Options{
+			Capacity:      1,
+			SkipNilValues: tt.skip,
+		})
+		c.Set("other", "value", 0)
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			evicted++
+		}
 
-			if err != nil && !tt.err {
-				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+		for r := 0; r < 2; r++ {
+			req := lru.GetOrAdd{
+				Key: "key",
+				Create: func() (interface{}, error) {
+					calls++
+					return tt.value, nil
+				},
 			}
-			if err == nil && tt.err {
-				t.Errorf("Apply(%d); got nil, expected an error", tn)
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
 			}
-			if i.Expires != tt.expire {
-				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.expire)
+			if req.Result != tt.value {
+				t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, req.Result, tt.value)
 			}
-		})
+		}
+
+		if calls != tt.calls {
+			t.Errorf("GetOrAdd(%d); got %d calls, expected %d", tn, calls, tt.calls)
+		}
+		if exp := 2 - tt.calls; evicted != exp {
+			t.Errorf("ItemEvicted(%d); got %d, expected %d", tn, evicted, exp)
+		}
+	}
+}
+
+func TestTypedCacheWithSkipNilValues(t *testing.T) {
+	var calls int
+
+	c := lru.NewTypedCache(lru.TypedOptions[int, []byte]{
+		SkipNilValues: true,
+	})
+
+	for r := 0; r < 2; r++ {
+		if _, err := c.GetOrAddFunc(1, 0, func() ([]byte, error) {
+			calls++
+			return nil, nil
+		}); err != nil {
+			t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("GetOrAddFunc(); got %d calls, expected 2", calls)
+	}
+}
+
+func TestCacheWarm(t *testing.T) {
+	errLoad := errors.New("error")
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 5,
+	})
+	c.Set("key_0", "existing", 0)
+
+	keys := make([]string, 5)
+	for idx := range keys {
+		keys[idx] = fmt.Sprintf("key_%d", idx)
+	}
+
+	var n atomic.Int32
+	err := c.Warm(keys, func(k string) (interface{}, error) {
+		n.Add(1)
+		if k == "key_3" {
+			return nil, errLoad
+		}
+
+		return k, nil
+	}, 0)
+
+	if !errors.Is(err, errLoad) {
+		t.Errorf("Warm(); got %v, expected %v", err, errLoad)
+	}
+	if act := n.Load(); act != 4 {
+		t.Errorf("Warm(); got %d loader invocations, expected 4", act)
+	}
+
+	exp := map[string]interface{}{
+		"key_0": "existing",
+		"key_1": "key_1",
+		"key_2": "key_2",
+		"key_4": "key_4",
+	}
+
+	if act := c.GetMulti(keys); fmt.Sprint(act) != fmt.Sprint(exp) {
+		t.Errorf("GetMulti(); got %v, expected %v", act, exp)
 	}
 }
 
-func TestSlidingExpirationPolicy(t *testing.T) {
-	now := time.Now()
+func TestCacheGetOrAddWithTTLFunc(t *testing.T) {
+	now := time.Now().UTC()
 
 	tests := []struct {
 		ttl    time.Duration
-		expire time.Time
 		access time.Time
-		err    bool
-		exp    time.Time
+		exp    bool
 	}{
 		{
-			ttl:    2 * time.Minute,
-			expire: now,
-			access: now.Add(1 * time.Minute),
-			err:    true,
-			exp:    now,
+			ttl:    1 * time.Minute,
+			access: now.Add(30 * time.Second),
+			exp:    true,
 		},
 		{
-			ttl:    2 * time.Minute,
-			expire: now,
-			access: now,
-			err:    true,
-			exp:    now,
+			ttl:    10 * time.Second,
+			access: now.Add(30 * time.Second),
+			exp:    false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewFixedExpirationPolicy(),
+		})
+
+		fixTime(now, func() {
+			act, err := c.GetOrAddWithTTLFunc("key", func() (interface{}, time.Duration, error) {
+				return "value", tt.ttl, nil
+			})
+			if err != nil || act != "value" {
+				t.Errorf("GetOrAddWithTTLFunc(%d); got %v, %v, expected value", tn, act, err)
+			}
+		})
+
+		fixTime(tt.access, func() {
+			if act := c.Contains("key"); act != tt.exp {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+	}
+}
+
+func TestCacheGetOrAddContext(t *testing.T) {
+	tests := []struct {
+		cancelBefore bool
+		cancelCreate bool
+		invocations  int
+		cached       bool
+	}{
+		{
+			invocations: 1,
+			cached:      true,
 		},
 		{
-			ttl:    2 * time.Minute,
-			expire: now.Add(1 * time.Minute),
-			access: now,
-			err:    false,
-			exp:    now.Add(2 * time.Minute),
+			cancelBefore: true,
+			invocations:  0,
+			cached:       false,
+		},
+		{
+			cancelCreate: true,
+			invocations:  1,
+			cached:       false,
 		},
 	}
 
 	for tn, tt := range tests {
-		fixTime(tt.access, func() {
-			i := lru.Item{Expires: tt.expire}
-			p := lru.NewSlidingExpirationPolicy(tt.ttl)
+		invocations := 0
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancelBefore {
+			cancel()
+		}
+
+		c := lru.NewCache(lru.Options{})
+		req := lru.GetOrAdd{
+			Key: "key",
+			Create: func() (interface{}, error) {
+				invocations++
+				if tt.cancelCreate {
+					cancel()
+				}
+				return "value", nil
+			},
+		}
+
+		err := c.GetOrAddContext(ctx, &req)
+		cancel()
+
+		if tt.cancelBefore || tt.cancelCreate {
+			if err != context.Canceled {
+				t.Errorf("GetOrAddContext(%d); got %v, expected %v", tn, err, context.Canceled)
+			}
+		} else if err != nil {
+			t.Errorf("GetOrAddContext(%d); got %v, expected nil", tn, err)
+		}
+		if invocations != tt.invocations {
+			t.Errorf("GetOrAddContext(%d); got %d func invocations, expected %d", tn, invocations, tt.invocations)
+		}
+		if act := c.Contains("key"); act != tt.cached {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.cached)
+		}
+	}
+}
+
+func TestCacheWithCreateContext(t *testing.T) {
+	type ctxKey struct{}
+
+	c := lru.NewCache(lru.Options{})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	req := lru.GetOrAdd{
+		Key: "key",
+		CreateContext: func(ctx context.Context) (interface{}, error) {
+			return ctx.Value(ctxKey{}), nil
+		},
+	}
+
+	if err := c.GetOrAddContext(ctx, &req); err != nil {
+		t.Errorf("GetOrAddContext(); got %v, expected nil", err)
+	}
+	if req.Result != "value" {
+		t.Errorf("GetOrAddContext(); got %v, expected value", req.Result)
+	}
+	if act := c.Contains("key"); !act {
+		t.Errorf("Contains(); got %v, expected true", act)
+	}
+}
+
+func TestCacheWithCreateContextCancellation(t *testing.T) {
+	tests := []struct {
+		waiters int
+		err     error
+		cached  bool
+	}{
+		{
+			waiters: 0,
+			err:     context.Canceled,
+			cached:  false,
+		},
+		{
+			waiters: 1,
+			err:     nil,
+			cached:  true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{})
+		release := make(chan struct{})
+		created := make(chan error, 1)
 
-			err := p.Apply(&i)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			req := lru.GetOrAdd{
+				Key: "key",
+				CreateContext: func(ctx context.Context) (interface{}, error) {
+					select {
+					case <-release:
+						created <- nil
+						return "value", nil
+					case <-ctx.Done():
+						created <- ctx.Err()
+						return nil, ctx.Err()
+					}
+				},
+			}
 
-			if err != nil && !tt.err {
-				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+			if err := c.GetOrAddContext(ctx, &req); err != context.Canceled {
+				t.Errorf("GetOrAddContext(%d); got %v, expected %v", tn, err, context.Canceled)
 			}
-			if err == nil && tt.err {
-				t.Errorf("Apply(%d); got nil, expected an error", tn)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+
+		var wg sync.WaitGroup
+		for idx := 0; idx < tt.waiters; idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				act, err := c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+					t.Errorf("Create(%d); got invocation, expected none", tn)
+					return nil, nil
+				})
+				if err != nil {
+					t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+				}
+				if act != "value" {
+					t.Errorf("GetOrAddFunc(%d); got %v, expected value", tn, act)
+				}
+			}()
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		if err := <-created; err != tt.err {
+			t.Errorf("CreateContext(%d); got %v, expected %v", tn, err, tt.err)
+		}
+
+		wg.Wait()
+		if act := c.Contains("key"); act != tt.cached {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.cached)
+		}
+	}
+}
+
+func TestCacheWithCreateTimeout(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		CreateTimeout: 20 * time.Millisecond,
+	})
+
+	release := make(chan struct{})
+	wg := new(sync.WaitGroup)
+
+	for r := 0; r < 5; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := lru.GetOrAdd{
+				Key: "key",
+				Create: func() (interface{}, error) {
+					<-release
+					return "value", nil
+				},
 			}
-			if i.Expires != tt.exp {
-				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.exp)
+
+			if err := c.GetOrAdd(&req); err != lru.ErrCreateTimeout {
+				t.Errorf("GetOrAdd(); got %v, expected %v", err, lru.ErrCreateTimeout)
 			}
-		})
+		}()
+	}
+
+	wg.Wait()
+	close(release)
+
+	for r := 0; r < 100 && !c.Contains("key"); r++ {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	if act := c.Contains("key"); !act {
+		t.Errorf("Contains(); got %v, expected true", act)
+	}
+}
+
+func TestCacheWithMaxConcurrentCreates(t *testing.T) {
+	o := lru.Options{
+		MaxConcurrentCreates: 2,
 	}
+
+	tests := []struct {
+		cache lru.Cacher
+	}{
+		{
+			cache: lru.NewCache(o),
+		},
+		{
+			cache: lru.NewShardedCache(o, 4),
+		},
+	}
+
+	for tn, tt := range tests {
+		var mu sync.Mutex
+		var active, peak int
+
+		var wg sync.WaitGroup
+		for idx := 0; idx < 8; idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, err := tt.cache.GetOrAddFunc(fmt.Sprintf("key_%d", idx), 0, func() (interface{}, error) {
+					mu.Lock()
+					active++
+					peak = max(peak, active)
+					mu.Unlock()
+
+					time.Sleep(10 * time.Millisecond)
+
+					mu.Lock()
+					active--
+					mu.Unlock()
+
+					return idx, nil
+				})
+				if err != nil {
+					t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		if peak != 2 {
+			t.Errorf("GetOrAddFunc(%d); got %d concurrent creates, expected 2", tn, peak)
+		}
+		if act := tt.cache.Len(); act != 8 {
+			t.Errorf("Len(%d); got %d, expected 8", tn, act)
+		}
+	}
+}
+
+func TestCacheWithMaxConcurrentCreatesCancellation(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		MaxConcurrentCreates: 1,
+	})
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if _, err := c.GetOrAddFunc("key_1", 0, func() (interface{}, error) {
+			<-release
+			return 1, nil
+		}); err != nil {
+			t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req := lru.GetOrAdd{
+		Key: "key_2",
+		Create: func() (interface{}, error) {
+			t.Errorf("Create(); got invocation, expected none")
+			return nil, nil
+		},
+	}
+
+	if err := c.GetOrAddContext(ctx, &req); err != context.DeadlineExceeded {
+		t.Errorf("GetOrAddContext(); got %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	<-done
+
+	if act := c.Keys(); fmt.Sprint(act) != "[key_1]" {
+		t.Errorf("Keys(); got %v, expected [key_1]", act)
+	}
+}
+
+func TestCacheDeduplication(t *testing.T) {
+	createErr := errors.New("error")
+
+	tests := []struct {
+		err error
+		exp interface{}
+	}{
+		{
+			err: nil,
+			exp: "value",
+		},
+		{
+			err: createErr,
+			exp: nil,
+		},
+	}
+
+	for tn, tt := range tests {
+		var invocations int32
+
+		c := lru.NewCache(lru.Options{})
+		release := make(chan struct{})
+		wg := new(sync.WaitGroup)
+
+		for r := 0; r < 10; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				req := lru.GetOrAdd{
+					Key: "key",
+					Create: func() (interface{}, error) {
+						atomic.AddInt32(&invocations, 1)
+						<-release
+
+						if tt.err != nil {
+							return nil, tt.err
+						}
+						return "value", nil
+					},
+				}
+
+				if err := c.GetOrAdd(&req); err != tt.err {
+					t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, tt.err)
+				}
+				if req.Result != tt.exp {
+					t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, req.Result, tt.exp)
+				}
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if invocations != 1 {
+			t.Errorf("GetOrAdd(%d); got %d func invocations, expected 1", tn, invocations)
+		}
+	}
+}
+
+func TestCacheDeduplicationWithContext(t *testing.T) {
+	c := lru.NewCache(lru.Options{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		req := lru.GetOrAdd{
+			Key: "key",
+			Create: func() (interface{}, error) {
+				<-release
+				return "value", nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req := lru.GetOrAdd{
+		Key: "key",
+		Create: func() (interface{}, error) {
+			t.Errorf("Create(); got invocation, expected none")
+			return nil, nil
+		},
+	}
+
+	if err := c.GetOrAddContext(ctx, &req); err != context.DeadlineExceeded {
+		t.Errorf("GetOrAddContext(); got %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	<-done
+
+	if !c.Contains("key") {
+		t.Errorf("Contains(); got false, expected true")
+	}
+}
+
+func TestCacheCreateOutsideLock(t *testing.T) {
+	c := lru.NewCache(lru.Options{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		req := lru.GetOrAdd{
+			Key: "key_1",
+			Create: func() (interface{}, error) {
+				<-release
+				return "created", nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+		if req.Result != "set" {
+			t.Errorf("GetOrAdd(); got %v, expected set", req.Result)
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// other operations must not block on the in-flight create
+	c.Set("key_2", "value", 0)
+	if !c.Contains("key_2") {
+		t.Errorf("Contains(); got false, expected true")
+	}
+
+	c.Set("key_1", "set", 0)
+
+	close(release)
+	<-done
+
+	req := lru.GetOrAdd{
+		Key: "key_1",
+		Create: func() (interface{}, error) {
+			t.Errorf("Create(); got invocation, expected none")
+			return nil, nil
+		},
+	}
+
+	if err := c.GetOrAdd(&req); err != nil {
+		t.Errorf("GetOrAdd(); got %v, expected nil", err)
+	}
+	if req.Result != "set" {
+		t.Errorf("GetOrAdd(); got %v, expected set", req.Result)
+	}
+}
+
+func TestCacheSlidingHitDoesNotEvict(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		capacity int
+		maxBytes int64
+	}{
+		{
+			capacity: 3,
+		},
+		{
+			maxBytes: 3,
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := 0
+
+		c := lru.NewCache(lru.Options{
+			Capacity: tt.capacity,
+			MaxBytes: tt.maxBytes,
+			Sizer:    func(interface{}) int64 { return 1 },
+			Policy:   lru.NewSlidingExpirationPolicy(1 * time.Minute),
+		})
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			evicted++
+		}
+
+		fixTime(now, func() {
+			for idx := 0; idx < 3; idx++ {
+				c.Set(fmt.Sprintf("key_%d", idx), idx, 1*time.Minute)
+			}
+		})
+
+		for idx := 0; idx < 9; idx++ {
+			fixTime(now.Add(time.Duration(idx)*15*time.Second), func() {
+				_, err := c.GetOrAddFunc(fmt.Sprintf("key_%d", idx%3), 1*time.Minute, func() (interface{}, error) {
+					t.Errorf("Create(%d); got invocation, expected none", tn)
+					return nil, nil
+				})
+				if err != nil {
+					t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+				}
+			})
+		}
+
+		if evicted != 0 {
+			t.Errorf("GetOrAddFunc(%d); got %d evictions, expected 0", tn, evicted)
+		}
+		if act := c.Len(); act != 3 {
+			t.Errorf("Len(%d); got %d, expected 3", tn, act)
+		}
+	}
+}
+
+func TestCacheExpiredEviction(t *testing.T) {
+	now := time.Now().UTC()
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewFixedExpirationPolicy(),
+	})
+
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%s:%v:%s", i.Key, i.Value, r))
+	}
+
+	value := 0
+	req := lru.GetOrAdd{
+		Key: "key",
+		TTL: 1 * time.Minute,
+		Create: func() (interface{}, error) {
+			value++
+			return value, nil
+		},
+	}
+
+	fixTime(now, func() {
+		c.GetOrAdd(&req)
+	})
+	fixTime(now.Add(90*time.Second), func() {
+		c.GetOrAdd(&req)
+	})
+
+	exp := []string{"key:1:expired"}
+	if fmt.Sprint(evicted) != fmt.Sprint(exp) {
+		t.Errorf("GetOrAdd(); got %v evicted, expected %v", evicted, exp)
+	}
+	if req.Result != 2 {
+		t.Errorf("GetOrAdd(); got %v, expected 2", req.Result)
+	}
+}
+
+func TestCacheExpiredVictim(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy     lru.ExpirationPolicy
+		itemPolicy lru.ExpirationPolicy
+		ttls       []time.Duration
+		evicted    []string
+		keys       []string
+	}{
+		{
+			policy:  lru.NewFixedExpirationPolicy(),
+			ttls:    []time.Duration{0, 0, 1 * time.Second},
+			evicted: []string{"key_2:expired"},
+			keys:    []string{"key_0", "key_1", "key_3"},
+		},
+		{
+			policy:  lru.NewFixedExpirationPolicy(),
+			ttls:    []time.Duration{0, 0, 0},
+			evicted: []string{"key_0:capacity"},
+			keys:    []string{"key_1", "key_2", "key_3"},
+		},
+		{
+			policy:     lru.NewNoExpirationPolicy(),
+			itemPolicy: lru.NewFixedExpirationPolicy(),
+			ttls:       []time.Duration{0, 0, 1 * time.Second},
+			evicted:    []string{"key_2:expired"},
+			keys:       []string{"key_0", "key_1", "key_3"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Policy:   tt.policy,
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		fixTime(now, func() {
+			for idx, ttl := range tt.ttls {
+				if tt.itemPolicy == nil {
+					c.Set(fmt.Sprintf("key_%d", idx), idx, ttl)
+					continue
+				}
+
+				c.GetOrAdd(&lru.GetOrAdd{
+					Key:    fmt.Sprintf("key_%d", idx),
+					TTL:    ttl,
+					Policy: tt.itemPolicy,
+					Create: func() (interface{}, error) {
+						return idx, nil
+					},
+				})
+			}
+		})
+
+		fixTime(now.Add(1*time.Minute), func() {
+			c.Set("key_3", 3, 0)
+		})
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("Set(%d); got %v evicted, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{})
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+	}
+
+	c.Set("key_1", 1, 0)
+	c.Set("key_2", 2, 0)
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close(); got %v, expected nil", err)
+	}
+
+	exp := []string{"key_1:removed", "key_2:removed"}
+	if fmt.Sprint(evicted) != fmt.Sprint(exp) {
+		t.Errorf("Close(); got %v evicted, expected %v", evicted, exp)
+	}
+
+	if err := c.Close(); err != lru.ErrClosed {
+		t.Errorf("Close(); got %v, expected %v", err, lru.ErrClosed)
+	}
+
+	req := lru.GetOrAdd{
+		Key: "key_1",
+		Create: func() (interface{}, error) {
+			t.Errorf("Create(); got invocation, expected none")
+			return nil, nil
+		},
+	}
+
+	if err := c.GetOrAdd(&req); err != lru.ErrClosed {
+		t.Errorf("GetOrAdd(); got %v, expected %v", err, lru.ErrClosed)
+	}
+
+	c.Set("key_3", 3, 0)
+	if act := c.Len(); act != 0 {
+		t.Errorf("Len(); got %d, expected 0", act)
+	}
+}
+
+func TestCacheWithClock(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		access time.Time
+		exp    bool
+	}{
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			access: now.Add(30 * time.Second),
+			exp:    true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			access: now.Add(90 * time.Second),
+			exp:    false,
+		},
+		{
+			policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			access: now.Add(90 * time.Second),
+			exp:    false,
+		},
+		{
+			policy: lru.NewJitteredExpirationPolicy(lru.NewFixedExpirationPolicy(), 0),
+			access: now.Add(90 * time.Second),
+			exp:    false,
+		},
+	}
+
+	for tn, tt := range tests {
+		clk := now
+
+		c := lru.NewCache(lru.Options{
+			Policy: tt.policy,
+			Clock: lru.ClockFunc(func() time.Time {
+				return clk
+			}),
+		})
+
+		c.Set("key", "value", 1*time.Minute)
+		clk = tt.access
+
+		if act := c.Contains("key"); act != tt.exp {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheItemTimestamps(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{})
+
+	var act lru.Item
+	c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+		act = *i
+	}
+
+	req := lru.GetOrAdd{
+		Key: "key",
+		Create: func() (interface{}, error) {
+			return "value", nil
+		},
+	}
+
+	fixTime(now, func() {
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+	})
+
+	fixTime(now.Add(1*time.Minute), func() {
+		c.Contains("key")
+	})
+
+	fixTime(now.Add(2*time.Minute), func() {
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+	})
+
+	c.Remove("key")
+
+	if !act.Created.Equal(now) {
+		t.Errorf("Created; got %v, expected %v", act.Created, now)
+	}
+	if exp := now.Add(2 * time.Minute); !act.LastAccess.Equal(exp) {
+		t.Errorf("LastAccess; got %v, expected %v", act.LastAccess, exp)
+	}
+}
+
+func TestCacheWithZeroTTL(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		ttl    time.Duration
+		exp    bool
+	}{
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    0,
+			exp:    true,
+		},
+		{
+			policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			ttl:    0,
+			exp:    true,
+		},
+		{
+			policy: lru.NewJitteredExpirationPolicy(lru.NewFixedExpirationPolicy(), 1*time.Minute),
+			ttl:    0,
+			exp:    true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    -1 * time.Second,
+			exp:    false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: tt.policy,
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", tt.ttl)
+
+			if act := c.Contains("key"); act != tt.exp {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+
+		fixTime(now.Add(24*time.Hour), func() {
+			if act := c.Contains("key"); act != tt.exp {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+	}
+}
+
+func TestCacheWithItemPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy     lru.ExpirationPolicy
+		itemPolicy lru.ExpirationPolicy
+		access     []time.Time
+		exp        bool
+	}{
+		{
+			policy:     lru.NewFixedExpirationPolicy(),
+			itemPolicy: nil,
+			access:     []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second)},
+			exp:        false,
+		},
+		{
+			policy:     lru.NewFixedExpirationPolicy(),
+			itemPolicy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			access:     []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second)},
+			exp:        true,
+		},
+		{
+			policy:     lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			itemPolicy: lru.NewFixedExpirationPolicy(),
+			access:     []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second)},
+			exp:        false,
+		},
+		{
+			policy:     lru.NewFixedExpirationPolicy(),
+			itemPolicy: lru.NewNoExpirationPolicy(),
+			access:     []time.Time{now.Add(2 * time.Minute)},
+			exp:        true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy:   tt.policy,
+			Eviction: lru.NewApproximateLRUEvictionPolicy,
+		})
+
+		req := lru.GetOrAdd{
+			Key:    "key",
+			TTL:    1 * time.Minute,
+			Policy: tt.itemPolicy,
+			Create: func() (interface{}, error) {
+				return "value", nil
+			},
+		}
+
+		fixTime(now, func() {
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		})
+
+		for _, a := range tt.access[:len(tt.access)-1] {
+			fixTime(a, func() {
+				if err := c.GetOrAdd(&req); err != nil {
+					t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+				}
+			})
+		}
+
+		fixTime(tt.access[len(tt.access)-1], func() {
+			if act := c.Contains("key"); act != tt.exp {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+	}
+}
+
+func TestCacheWithRefreshThreshold(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy      lru.ExpirationPolicy
+		access      time.Time
+		invocations int32
+	}{
+		{
+			policy:      lru.NewFixedExpirationPolicy(),
+			access:      now.Add(30 * time.Second),
+			invocations: 1,
+		},
+		{
+			policy:      lru.NewFixedExpirationPolicy(),
+			access:      now.Add(55 * time.Second),
+			invocations: 2,
+		},
+		{
+			policy:      lru.NewNoExpirationPolicy(),
+			access:      now.Add(55 * time.Second),
+			invocations: 1,
+		},
+	}
+
+	for tn, tt := range tests {
+		var invocations int32
+
+		clk := now
+		release := make(chan struct{})
+
+		c := lru.NewCache(lru.Options{
+			Policy:           tt.policy,
+			RefreshThreshold: 10 * time.Second,
+			Clock: lru.ClockFunc(func() time.Time {
+				return clk
+			}),
+		})
+
+		req := lru.GetOrAdd{
+			Key: "key",
+			TTL: 1 * time.Minute,
+			Create: func() (interface{}, error) {
+				n := atomic.AddInt32(&invocations, 1)
+				if n > 1 {
+					<-release
+				}
+				return n, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+		}
+
+		clk = tt.access
+
+		for r := 0; r < 2; r++ {
+			if err := c.GetOrAdd(&req); err != nil || req.Result != int32(1) {
+				t.Errorf("GetOrAdd(%d); got %v, %v, expected 1", tn, req.Result, err)
+			}
+		}
+
+		close(release)
+
+		for r := 0; r < 100 && req.Result != tt.invocations; r++ {
+			time.Sleep(1 * time.Millisecond)
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		if act := atomic.LoadInt32(&invocations); act != tt.invocations {
+			t.Errorf("GetOrAdd(%d); got %d func invocations, expected %d", tn, act, tt.invocations)
+		}
+		if req.Result != tt.invocations {
+			t.Errorf("GetOrAdd(%d); got %v, expected %d", tn, req.Result, tt.invocations)
+		}
+	}
+}
+
+func TestCacheWithRefreshThresholdUpdated(t *testing.T) {
+	tests := []struct {
+		fn  func(c *lru.Cache)
+		exp interface{}
+	}{
+		{
+			fn: func(c *lru.Cache) {
+				c.Remove("key")
+			},
+			exp: nil,
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Set("key", "set", 1*time.Minute)
+			},
+			exp: "set",
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.GetOrAdd(&lru.GetOrAdd{
+					Key:   "key",
+					TTL:   1 * time.Minute,
+					Force: true,
+					Create: func() (interface{}, error) {
+						return "forced", nil
+					},
+				})
+			},
+			exp: "forced",
+		},
+	}
+
+	for tn, tt := range tests {
+		var now atomic.Int64
+		now.Store(time.Now().UnixNano())
+
+		var invocations atomic.Int32
+		release := make(chan struct{})
+		created := make(chan struct{}, 1)
+
+		c := lru.NewCache(lru.Options{
+			Policy:           lru.NewFixedExpirationPolicy(),
+			RefreshThreshold: 10 * time.Second,
+			Clock: lru.ClockFunc(func() time.Time {
+				return time.Unix(0, now.Load()).UTC()
+			}),
+		})
+
+		req := lru.GetOrAdd{
+			Key: "key",
+			TTL: 1 * time.Minute,
+			Create: func() (interface{}, error) {
+				n := invocations.Add(1)
+				if n == 2 {
+					<-release
+					created <- struct{}{}
+				}
+				return n, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+		}
+
+		// the hit starts a refresh, which is in-flight when the item is updated
+		now.Add(int64(55 * time.Second))
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+		}
+
+		tt.fn(c)
+		close(release)
+		<-created
+
+		// allow the refresh to complete
+		time.Sleep(10 * time.Millisecond)
+
+		var act interface{}
+		c.Range(func(_ string, v interface{}) bool {
+			act = v
+			return true
+		})
+		if act != tt.exp {
+			t.Errorf("Range(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheWithReapInterval(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Now().UnixNano())
+
+	evicted := make(chan string, 10)
+
+	c := lru.NewCache(lru.Options{
+		Policy:       lru.NewSlidingExpirationPolicy(1 * time.Minute),
+		ReapInterval: 5 * time.Millisecond,
+		Clock: lru.ClockFunc(func() time.Time {
+			return time.Unix(0, now.Load()).UTC()
+		}),
+	})
+	defer c.Close()
+
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		evicted <- fmt.Sprintf("%s:%s", i.Key, r)
+	}
+
+	c.Set("key_1", 1, 1*time.Minute)
+	c.Set("key_2", 2, 2*time.Minute)
+
+	now.Add(int64(90 * time.Second))
+
+	select {
+	case act := <-evicted:
+		if act != "key_1:expired" {
+			t.Errorf("ItemEvicted(); got %s, expected key_1:expired", act)
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("ItemEvicted(); got no invocation, expected key_1:expired")
+	}
+
+	// the reaper must not slide live items
+	now.Add(int64(45 * time.Second))
+
+	select {
+	case act := <-evicted:
+		if act != "key_2:expired" {
+			t.Errorf("ItemEvicted(); got %s, expected key_2:expired", act)
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("ItemEvicted(); got no invocation, expected key_2:expired")
+	}
+}
+
+func TestCacheWithExpiryTimers(t *testing.T) {
+	evicted := make(chan string, 10)
+
+	c := lru.NewCache(lru.Options{
+		Policy:       lru.NewSlidingExpirationPolicy(50 * time.Millisecond),
+		ExpiryTimers: true,
+	})
+	defer c.Close()
+
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		evicted <- fmt.Sprintf("%s:%s", i.Key, r)
+	}
+
+	start := time.Now()
+	c.Set("key_1", 1, 50*time.Millisecond)
+	c.Set("key_2", 2, 50*time.Millisecond)
+	c.Set("key_3", 3, 0)
+
+	c.Remove("key_2")
+	if act := <-evicted; act != "key_2:removed" {
+		t.Errorf("ItemEvicted(); got %s, expected key_2:removed", act)
+	}
+
+	// the timer must be rescheduled for the extended expiry
+	time.Sleep(25 * time.Millisecond)
+	c.Touch("key_1")
+
+	select {
+	case act := <-evicted:
+		if act != "key_1:expired" {
+			t.Errorf("ItemEvicted(); got %s, expected key_1:expired", act)
+		}
+		if d := time.Since(start); d < 75*time.Millisecond {
+			t.Errorf("ItemEvicted(); got invocation after %v, expected at least 75ms", d)
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("ItemEvicted(); got no invocation, expected key_1:expired")
+	}
+
+	if act := c.Keys(); fmt.Sprint(act) != "[key_3]" {
+		t.Errorf("Keys(); got %v, expected [key_3]", act)
+	}
+
+	select {
+	case act := <-evicted:
+		t.Errorf("ItemEvicted(); got %s, expected none", act)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCacheWithInitialCapacity(t *testing.T) {
+	keys := make([]string, 1000)
+	for idx := range keys {
+		keys[idx] = fmt.Sprintf("key_%d", idx)
+	}
+
+	fill := func(initial int) float64 {
+		return testing.AllocsPerRun(5, func() {
+			c := lru.NewCache(lru.Options{
+				Capacity:        len(keys),
+				InitialCapacity: initial,
+			})
+
+			for _, k := range keys {
+				c.Set(k, nil, 0)
+			}
+		})
+	}
+
+	if act, exp := fill(len(keys)), fill(0); act >= exp {
+		t.Errorf("Set(); got %v allocations, expected fewer than %v", act, exp)
+	}
+}
+
+func TestCacheWithMaxBytes(t *testing.T) {
+	tests := []struct {
+		capacity int
+		maxBytes int64
+		values   []string
+		keys     []string
+		bytes    int64
+	}{
+		{
+			maxBytes: 10,
+			values:   []string{"aaaa", "bbbb", "cc"},
+			keys:     []string{"0", "1", "2"},
+			bytes:    10,
+		},
+		{
+			maxBytes: 10,
+			values:   []string{"aaaa", "bbbb", "ccc"},
+			keys:     []string{"1", "2"},
+			bytes:    7,
+		},
+		{
+			maxBytes: 10,
+			values:   []string{"aaaa", "bbbb", "cccccccc"},
+			keys:     []string{"2"},
+			bytes:    8,
+		},
+		{
+			maxBytes: 10,
+			values:   []string{"aaaa", "bbbbbbbbbbbb", "c"},
+			keys:     []string{"2"},
+			bytes:    1,
+		},
+		{
+			capacity: 2,
+			maxBytes: 10,
+			values:   []string{"a", "b", "c"},
+			keys:     []string{"1", "2"},
+			bytes:    2,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: tt.capacity,
+			MaxBytes: tt.maxBytes,
+			Sizer: func(v interface{}) int64 {
+				return int64(len(v.(string)))
+			},
+		})
+
+		for idx, v := range tt.values {
+			c.Set(fmt.Sprint(idx), v, 0)
+		}
+
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+		if act := c.Stats().Bytes; act != tt.bytes {
+			t.Errorf("Stats(%d); got %d bytes, expected %d", tn, act, tt.bytes)
+		}
+	}
+}
+
+func TestCacheWithMaxBytesReplace(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		MaxBytes: 10,
+		Sizer: func(v interface{}) int64 {
+			return int64(len(v.(string)))
+		},
+	})
+
+	c.Set("key_1", "aaaa", 0)
+	c.Set("key_2", "bbbb", 0)
+	c.Set("key_2", "bbbbbb", 0)
+
+	if act := c.Stats().Bytes; act != 10 {
+		t.Errorf("Stats(); got %d bytes, expected 10", act)
+	}
+
+	c.Set("key_2", "bbbbbbbb", 0)
+
+	if act := c.Keys(); fmt.Sprint(act) != "[key_2]" {
+		t.Errorf("Keys(); got %v, expected [key_2]", act)
+	}
+	if act := c.Stats().Bytes; act != 8 {
+		t.Errorf("Stats(); got %d bytes, expected 8", act)
+	}
+
+	c.Remove("key_2")
+	if act := c.Stats().Bytes; act != 0 {
+		t.Errorf("Stats(); got %d bytes, expected 0", act)
+	}
+}
+
+func TestCacheWithWeight(t *testing.T) {
+	tests := []struct {
+		capacity int
+		weights  []int
+		keys     []string
+	}{
+		{
+			capacity: 5,
+			weights:  []int{0, 0, 0},
+			keys:     []string{"0", "1", "2"},
+		},
+		{
+			capacity: 5,
+			weights:  []int{2, 2, 1},
+			keys:     []string{"0", "1", "2"},
+		},
+		{
+			capacity: 5,
+			weights:  []int{2, 2, 2},
+			keys:     []string{"1", "2"},
+		},
+		{
+			capacity: 5,
+			weights:  []int{1, 1, 1, 4},
+			keys:     []string{"2", "3"},
+		},
+		{
+			capacity: 5,
+			weights:  []int{1, 1, 8},
+			keys:     []string{"2"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: tt.capacity,
+		})
+
+		for idx, w := range tt.weights {
+			err := c.GetOrAdd(&lru.GetOrAdd{
+				Key:    fmt.Sprint(idx),
+				Weight: w,
+				Create: func() (interface{}, error) {
+					return idx, nil
+				},
+			})
+			if err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheParallel(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Capacity: 100,
+	})
+
+	wg := new(sync.WaitGroup)
+
+	for r := 0; r < 100; r++ {
+		wg.Add(1)
+		go func(c *lru.Cache) {
+			defer wg.Done()
+
+			for o := 0; o < 100; o++ {
+				exp := fmt.Sprintf("value:%d", o)
+
+				req := lru.GetOrAdd{
+					Key: fmt.Sprintf("key:%d", o),
+					Create: func() (interface{}, error) {
+						return exp, nil
+					},
+				}
+
+				if err := c.GetOrAdd(&req); err != nil {
+					t.Errorf("GetOrAdd(); got %v, expected nil", err)
+				}
+				if req.Result != exp {
+					t.Errorf("GetOrAdd(); got %s, expected %s", req.Result, exp)
+				}
+			}
+		}(c)
+	}
+
+	wg.Wait()
+}
+
+func TestTypedCache(t *testing.T) {
+	type value struct {
+		id int
+	}
+
+	invocations := 0
+
+	c := lru.NewTypedCache(lru.TypedOptions[int, value]{
+		Capacity: 2,
+		Policy:   lru.NewTypedFixedExpirationPolicy[int, value](),
+	})
+
+	for idx := 0; idx < 10; idx++ {
+		key := idx % 2
+		req := lru.TypedGetOrAdd[int, value]{
+			Key: key,
+			TTL: 1 * time.Minute,
+			Create: func() (value, error) {
+				invocations++
+				return value{id: key}, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", idx, err)
+		}
+		if req.Result.id != key {
+			t.Errorf("GetOrAdd(%d); got %d, expected %d", idx, req.Result.id, key)
+		}
+	}
+
+	if invocations != 2 {
+		t.Errorf("GetOrAdd(); got %d func invocations, expected 2", invocations)
+	}
+	if keys := c.Keys(); len(keys) != 2 || keys[0] != 0 || keys[1] != 1 {
+		t.Errorf("Keys(); got %v, expected [0 1]", keys)
+	}
+}
+
+func TestTypedCacheWithCompositeKey(t *testing.T) {
+	type key struct {
+		tenant   string
+		resource string
+	}
+
+	c := lru.NewTypedCache(lru.TypedOptions[key, string]{})
+
+	c.Set(key{tenant: "a", resource: "bc"}, "a/bc", 0)
+	c.Set(key{tenant: "ab", resource: "c"}, "ab/c", 0)
+
+	if act := c.Len(); act != 2 {
+		t.Errorf("Len(); got %d, expected 2", act)
+	}
+
+	for k, exp := range map[key]string{
+		{tenant: "a", resource: "bc"}: "a/bc",
+		{tenant: "ab", resource: "c"}: "ab/c",
+	} {
+		act, err := c.GetOrAddFunc(k, 0, func() (string, error) {
+			t.Errorf("Create(); got invocation, expected none")
+			return "", nil
+		})
+		if err != nil || act != exp {
+			t.Errorf("GetOrAddFunc(%v); got %v, %v, expected %s", k, act, err, exp)
+		}
+	}
+}
+
+func TestTypedCacheWithInterfaceKey(t *testing.T) {
+	type key struct {
+		id int
+	}
+
+	c := lru.NewTypedCache(lru.TypedOptions[interface{}, string]{})
+
+	c.Set(1, "int", 0)
+	c.Set("1", "string", 0)
+	c.Set(key{id: 1}, "struct", 0)
+
+	for k, exp := range map[interface{}]string{
+		1:          "int",
+		"1":        "string",
+		key{id: 1}: "struct",
+		int64(1):   "",
+		key{id: 2}: "",
+	} {
+		act, _ := c.GetOrAddFunc(k, 0, func() (string, error) {
+			return "", nil
+		})
+		if act != exp {
+			t.Errorf("GetOrAddFunc(%v); got %s, expected %s", k, act, exp)
+		}
+	}
+}
+
+func TestCacheWithApproximateLRU(t *testing.T) {
+	tests := []struct {
+		policy  lru.ExpirationPolicy
+		access  []string
+		evicted []string
+	}{
+		{
+			policy:  lru.NewNoExpirationPolicy(),
+			access:  []string{},
+			evicted: []string{"key_1"},
+		},
+		{
+			policy:  lru.NewNoExpirationPolicy(),
+			access:  []string{"key_1"},
+			evicted: []string{"key_2"},
+		},
+		{
+			policy:  lru.NewFixedExpirationPolicy(),
+			access:  []string{"key_1", "key_2"},
+			evicted: []string{"key_1"},
+		},
+		{
+			policy:  lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			access:  []string{"key_1"},
+			evicted: []string{"key_2"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Policy:   tt.policy,
+			Eviction: lru.NewApproximateLRUEvictionPolicy,
+		})
+
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		c.Set("key_1", 1, 1*time.Minute)
+		c.Set("key_2", 2, 1*time.Minute)
+
+		for _, k := range tt.access {
+			req := lru.GetOrAdd{
+				Key: k,
+				Create: func() (interface{}, error) {
+					t.Errorf("Create(%d); got invocation, expected none", tn)
+					return nil, nil
+				},
+			}
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		c.Set("key_3", 3, 1*time.Minute)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("Set(%d); got %v evicted, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
+
+func TestCacheWithApproximateLRUParallel(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Capacity: 50,
+		Eviction: lru.NewApproximateLRUEvictionPolicy,
+	})
+
+	wg := new(sync.WaitGroup)
+
+	for r := 0; r < 100; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for o := 0; o < 100; o++ {
+				exp := o % 60
+				req := lru.GetOrAdd{
+					Key: fmt.Sprintf("key:%d", exp),
+					Create: func() (interface{}, error) {
+						return exp, nil
+					},
+				}
+
+				if err := c.GetOrAdd(&req); err != nil {
+					t.Errorf("GetOrAdd(); got %v, expected nil", err)
+				}
+				if req.Result != exp {
+					t.Errorf("GetOrAdd(); got %v, expected %d", req.Result, exp)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestCacheSet(t *testing.T) {
+	now := time.Now().UTC()
+	evicted := []string{}
+	replaced := []interface{}{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 2,
+		Policy:   lru.NewFixedExpirationPolicy(),
+	})
+
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		switch r {
+		case lru.EvictCapacity, lru.EvictExpired:
+			evicted = append(evicted, i.Key)
+		case lru.EvictReplaced:
+			replaced = append(replaced, i.Value)
+		}
+	}
+
+	fixTime(now, func() {
+		c.Set("key_1", 1, 1*time.Minute)
+		c.Set("key_2", 2, 1*time.Minute)
+		c.Set("key_1", 3, 2*time.Minute)
+	})
+	if len(evicted) != 0 {
+		t.Errorf("Set(); got %d evictions, expected 0", len(evicted))
+	}
+	if len(replaced) != 1 || replaced[0] != 1 {
+		t.Errorf("Set(); got %v replaced, expected [1]", replaced)
+	}
+
+	fixTime(now.Add(90*time.Second), func() {
+		c.Set("key_3", 4, 1*time.Minute)
+	})
+	if len(evicted) != 1 || evicted[0] != "key_2" {
+		t.Errorf("Set(); got %v evicted, expected [key_2]", evicted)
+	}
+
+	fixTime(now.Add(90*time.Second), func() {
+		req := lru.GetOrAdd{
+			Key: "key_1",
+			Create: func() (interface{}, error) {
+				t.Errorf("Create(); got invocation, expected none")
+				return nil, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+		if req.Result != 3 {
+			t.Errorf("GetOrAdd(); got %v, expected 3", req.Result)
+		}
+	})
+}
+
+func TestCacheSetPrevious(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		key     string
+		access  time.Time
+		old     interface{}
+		existed bool
+		evicted []string
+	}{
+		{
+			key:     "key",
+			access:  now.Add(30 * time.Second),
+			old:     "value",
+			existed: true,
+			evicted: []string{"value:replaced"},
+		},
+		{
+			key:     "other",
+			access:  now.Add(30 * time.Second),
+			old:     nil,
+			existed: false,
+			evicted: []string{},
+		},
+		{
+			key:     "key",
+			access:  now.Add(2 * time.Minute),
+			old:     nil,
+			existed: false,
+			evicted: []string{"value:expired"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewFixedExpirationPolicy(),
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%v:%s", i.Value, r))
+		}
+
+		fixTime(now, func() {
+			c.Set("key", "value", 1*time.Minute)
+		})
+
+		fixTime(tt.access, func() {
+			old, existed := c.Set(tt.key, "updated", 1*time.Minute)
+			if old != tt.old || existed != tt.existed {
+				t.Errorf("Set(%d); got %v, %v, expected %v, %v", tn, old, existed, tt.old, tt.existed)
+			}
+		})
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
+
+func TestCacheGetMulti(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy  lru.ExpirationPolicy
+		keys    []string
+		access  time.Time
+		exp     map[string]interface{}
+		expires time.Time
+	}{
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			keys:   []string{"key_1", "key_2", "other"},
+			access: now.Add(2 * time.Minute),
+			exp:    map[string]interface{}{"key_1": "value_1", "key_2": "value_2"},
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			keys:   []string{"key_1", "key_2"},
+			access: now.Add(2 * time.Minute),
+			exp:    map[string]interface{}{},
+		},
+		{
+			policy:  lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			keys:    []string{"key_1", "key_2"},
+			access:  now.Add(30 * time.Second),
+			exp:     map[string]interface{}{"key_1": "value_1", "key_2": "value_2"},
+			expires: now.Add(90 * time.Second),
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Policy:   tt.policy,
+		})
+
+		fixTime(now, func() {
+			c.Set("key_1", "value_1", 1*time.Minute)
+			c.Set("key_2", "value_2", 1*time.Minute)
+			c.Set("key_3", "value_3", 1*time.Minute)
+		})
+
+		fixTime(tt.access, func() {
+			act := c.GetMulti(tt.keys)
+			if fmt.Sprint(act) != fmt.Sprint(tt.exp) {
+				t.Errorf("GetMulti(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+
+		if len(tt.exp) > 0 {
+			if act := c.Keys(); act[len(act)-1] != tt.keys[1] {
+				t.Errorf("Keys(%d); got %v, expected %s last", tn, act, tt.keys[1])
+			}
+		}
+
+		if !tt.expires.IsZero() {
+			fixTime(tt.expires.Add(-1*time.Second), func() {
+				if act := c.Contains("key_1"); !act {
+					t.Errorf("Contains(%d); got %v, expected true", tn, act)
+				}
+			})
+		}
+	}
+}
+
+func TestCacheSetMulti(t *testing.T) {
+	tests := []struct {
+		capacity int
+		items    []lru.Item
+		keys     []string
+	}{
+		{
+			capacity: 3,
+			items:    []lru.Item{{Key: "key_1", Value: 1}, {Key: "key_2", Value: 2}},
+			keys:     []string{"key_0", "key_1", "key_2"},
+		},
+		{
+			capacity: 3,
+			items:    []lru.Item{{Key: "key_1", Value: 1}, {Key: "key_2", Value: 2}, {Key: "key_3", Value: 3}},
+			keys:     []string{"key_1", "key_2", "key_3"},
+		},
+		{
+			capacity: 2,
+			items:    []lru.Item{{Key: "key_1", Value: 1}, {Key: "key_2", Value: 2}, {Key: "key_3", Value: 3}},
+			keys:     []string{"key_2", "key_3"},
+		},
+		{
+			capacity: 3,
+			items:    []lru.Item{{Key: "key_0", Value: 1}, {Key: "key_1", Value: 2}},
+			keys:     []string{"key_0", "key_1"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: tt.capacity,
+		})
+
+		c.Set("key_0", 0, 0)
+		c.SetMulti(tt.items, 0)
+
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+
+		exp := tt.items[len(tt.items)-1]
+		if act := c.GetMulti([]string{exp.Key})[exp.Key]; act != exp.Value {
+			t.Errorf("GetMulti(%d); got %v, expected %v", tn, act, exp.Value)
+		}
+	}
+}
+
+func TestCacheSetMultiBatchSize(t *testing.T) {
+	tests := []struct {
+		batchSize int
+		exp       []string
+	}{
+		{
+			batchSize: 0,
+			exp:       []string{"a:[d]", "b:[d]", "c:[d]"},
+		},
+		{
+			batchSize: 2,
+			exp:       []string{"a:[b]", "b:[d]", "c:[d]"},
+		},
+		{
+			batchSize: 1,
+			exp:       []string{"a:[b]", "b:[c]", "c:[d]"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity:        1,
+			BatchSize:       tt.batchSize,
+			UnlockedEvicted: true,
+		})
+
+		// evictions are deferred until the lock is released after each batch
+		act := []string{}
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			act = append(act, fmt.Sprintf("%s:%v", i.Key, c.Keys()))
+		}
+
+		c.SetMulti([]lru.Item{{Key: "a"}, {Key: "b"}, {Key: "c"}, {Key: "d"}}, 0)
+
+		if fmt.Sprint(act) != fmt.Sprint(tt.exp) {
+			t.Errorf("SetMulti(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheUpdateValue(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		key    string
+		access time.Time
+		exp    bool
+		keys   []string
+	}{
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "key_1",
+			access: now.Add(30 * time.Second),
+			exp:    true,
+			keys:   []string{"key_2", "key_1"},
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "other",
+			access: now.Add(30 * time.Second),
+			exp:    false,
+			keys:   []string{"key_1", "key_2"},
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "key_1",
+			access: now.Add(2 * time.Minute),
+			exp:    false,
+			keys:   []string{"key_2"},
+		},
+	}
+
+	for tn, tt := range tests {
+		var replaced []interface{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Policy:   tt.policy,
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			if r == lru.EvictReplaced {
+				replaced = append(replaced, i.Value)
+			}
+		}
+
+		fixTime(now, func() {
+			c.Set("key_1", "value_1", 1*time.Minute)
+			c.Set("key_2", "value_2", 1*time.Minute)
+		})
+
+		fixTime(tt.access, func() {
+			if act := c.UpdateValue(tt.key, "updated"); act != tt.exp {
+				t.Errorf("UpdateValue(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+
+		if !tt.exp {
+			if len(replaced) != 0 {
+				t.Errorf("ItemEvicted(%d); got %v, expected none", tn, replaced)
+			}
+			continue
+		}
+
+		if fmt.Sprint(replaced) != "[value_1]" {
+			t.Errorf("ItemEvicted(%d); got %v, expected [value_1]", tn, replaced)
+		}
+
+		fixTime(now.Add(30*time.Second), func() {
+			req := lru.GetOrAdd{Key: tt.key}
+			if err := c.GetOrAdd(&req); err != nil || req.Result != "updated" {
+				t.Errorf("GetOrAdd(%d); got %v, expected updated", tn, req.Result)
+			}
+		})
+
+		fixTime(now.Add(1*time.Minute), func() {
+			if act := c.Contains(tt.key); act {
+				t.Errorf("Contains(%d); got %v, expected false", tn, act)
+			}
+		})
+	}
+}
+
+func TestCacheUpdateValueEvictionState(t *testing.T) {
+	tests := []struct {
+		eviction func() lru.EvictionPolicy
+	}{
+		{eviction: lru.NewLRUEvictionPolicy},
+		{eviction: lru.NewLFUEvictionPolicy},
+		{eviction: lru.NewTwoQueueEvictionPolicy},
+		{eviction: lru.NewARCEvictionPolicy},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Eviction: tt.eviction,
+		})
+
+		for idx := 0; idx < 3; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+		c.Touch("key_0")
+		c.Touch("key_1")
+
+		// the update is an access, rather than a newly added item
+		if act := c.UpdateValue("key_0", "updated"); !act {
+			t.Errorf("UpdateValue(%d); got %v, expected true", tn, act)
+		}
+
+		exp := []string{"key_2", "key_1", "key_0"}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(exp) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, exp)
+		}
+	}
+
+	evicted := []string{}
+	c := lru.NewCache(lru.Options{
+		MaxBytes: 10,
+		Sizer: func(v interface{}) int64 {
+			return int64(len(v.(string)))
+		},
+	})
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+	}
+
+	c.Set("key_1", "aaaa", 0)
+	c.Set("key_2", "bbbb", 0)
+	c.UpdateValue("key_1", "aaaaaaaa")
+
+	exp := []string{"key_2:capacity", "key_1:replaced"}
+	if fmt.Sprint(evicted) != fmt.Sprint(exp) {
+		t.Errorf("ItemEvicted(); got %v, expected %v", evicted, exp)
+	}
+	if act := c.Stats().Bytes; act != 8 {
+		t.Errorf("Stats(); got %d bytes, expected 8", act)
+	}
+}
+
+func TestCacheGet(t *testing.T) {
+	errLoad := errors.New("error")
+
+	tests := []struct {
+		create func() (interface{}, error)
+		loader func(string) (interface{}, error)
+		exp    interface{}
+		err    error
+	}{
+		{
+			loader: func(k string) (interface{}, error) {
+				return k + "_value", nil
+			},
+			exp: "key_value",
+		},
+		{
+			loader: func(string) (interface{}, error) {
+				return nil, errLoad
+			},
+			err: errLoad,
+		},
+		{
+			create: func() (interface{}, error) {
+				return "create", nil
+			},
+			loader: func(string) (interface{}, error) {
+				t.Error("Loader(); got invocation, expected none")
+				return nil, nil
+			},
+			exp: "create",
+		},
+	}
+
+	for tn, tt := range tests {
+		var n int
+		c := lru.NewCache(lru.Options{
+			Loader: tt.loader,
+		})
+		c.ItemAdded = func(*lru.Item) {
+			n++
+		}
+
+		if tt.create != nil {
+			if _, err := c.GetOrAddFunc("key", 0, tt.create); err != nil {
+				t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		for idx := 0; idx < 2; idx++ {
+			act, err := c.Get("key", 1*time.Minute)
+			if err != tt.err {
+				t.Errorf("Get(%d); got %v, expected %v", tn, err, tt.err)
+			}
+			if act != tt.exp {
+				t.Errorf("Get(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		}
+
+		exp := 1
+		if tt.err != nil {
+			exp = 0
+		}
+		if n != exp {
+			t.Errorf("Get(%d); got %d items added, expected %d", tn, n, exp)
+		}
+	}
+}
+
+func TestCacheCompareAndSwap(t *testing.T) {
+	tests := []struct {
+		equal func(a, b interface{}) bool
+		value interface{}
+		key   string
+		old   interface{}
+		exp   bool
+	}{
+		{
+			value: "value",
+			key:   "key",
+			old:   "value",
+			exp:   true,
+		},
+		{
+			value: "value",
+			key:   "key",
+			old:   "other",
+			exp:   false,
+		},
+		{
+			value: "value",
+			key:   "other",
+			old:   "value",
+			exp:   false,
+		},
+		{
+			value: []string{"value"},
+			key:   "key",
+			old:   []string{"value"},
+			exp:   false,
+		},
+		{
+			equal: func(a, b interface{}) bool {
+				return fmt.Sprint(a) == fmt.Sprint(b)
+			},
+			value: []string{"value"},
+			key:   "key",
+			old:   []string{"value"},
+			exp:   true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Equal: tt.equal,
+		})
+		c.Set("key", tt.value, 0)
+
+		if act := c.CompareAndSwap(tt.key, tt.old, "new"); act != tt.exp {
+			t.Errorf("CompareAndSwap(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+
+		exp := tt.value
+		if tt.exp {
+			exp = "new"
+		}
+
+		act, _ := c.GetOrAddFunc("key", 0, nil)
+		if fmt.Sprint(act) != fmt.Sprint(exp) {
+			t.Errorf("GetOrAddFunc(%d); got %v, expected %v", tn, act, exp)
+		}
+	}
+}
+
+func TestCacheCompareAndSwapExpiry(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+	})
+
+	fixTime(now, func() {
+		c.Set("key", "value", 1*time.Minute)
+	})
+
+	fixTime(now.Add(30*time.Second), func() {
+		if act := c.CompareAndSwap("key", "value", "new"); !act {
+			t.Errorf("CompareAndSwap(); got %v, expected true", act)
+		}
+	})
+
+	fixTime(now.Add(1*time.Minute), func() {
+		if act := c.CompareAndSwap("key", "new", "other"); act {
+			t.Errorf("CompareAndSwap(); got %v, expected false", act)
+		}
+		if act := c.Len(); act != 0 {
+			t.Errorf("Len(); got %d, expected 0", act)
+		}
+	})
+}
+
+func TestCacheSetPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		initial lru.ExpirationPolicy
+		policy  lru.ExpirationPolicy
+		access  []time.Duration
+		exp     bool
+	}{
+		{
+			initial: lru.NewFixedExpirationPolicy(),
+			policy:  lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			access:  []time.Duration{45 * time.Second, 50 * time.Second, 90 * time.Second},
+			exp:     true,
+		},
+		{
+			initial: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			policy:  lru.NewFixedExpirationPolicy(),
+			access:  []time.Duration{45 * time.Second, 90 * time.Second},
+			exp:     true,
+		},
+		{
+			initial: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			policy:  lru.NewFixedExpirationPolicy(),
+			access:  []time.Duration{45 * time.Second, 90 * time.Second, 105 * time.Second},
+			exp:     false,
+		},
+		{
+			initial: lru.NewFixedExpirationPolicy(),
+			policy:  nil,
+			access:  []time.Duration{90 * time.Second},
+			exp:     true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: tt.initial,
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", 1*time.Minute)
+		})
+
+		for idx, d := range tt.access {
+			fixTime(now.Add(d), func() {
+				if idx == 0 {
+					c.Touch("key")
+					c.SetPolicy(tt.policy)
+					return
+				}
+
+				if idx < len(tt.access)-1 {
+					c.Touch("key")
+					return
+				}
+
+				if act := c.Contains("key"); act != tt.exp {
+					t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+				}
+			})
+		}
+	}
+}
+
+func TestCacheGetStale(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		key    string
+		access time.Duration
+		value  interface{}
+		stale  bool
+		ok     bool
+	}{
+		{
+			key:    "key",
+			access: 30 * time.Second,
+			value:  "value",
+			stale:  false,
+			ok:     true,
+		},
+		{
+			key:    "key",
+			access: 90 * time.Second,
+			value:  "value",
+			stale:  true,
+			ok:     true,
+		},
+		{
+			key:    "other",
+			access: 30 * time.Second,
+			value:  nil,
+			stale:  false,
+			ok:     false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", 1*time.Minute)
+		})
+
+		fixTime(now.Add(tt.access), func() {
+			for idx := 0; idx < 2; idx++ {
+				value, stale, ok := c.GetStale(tt.key)
+				if value != tt.value || stale != tt.stale || ok != tt.ok {
+					t.Errorf("GetStale(%d); got %v, %v, %v, expected %v, %v, %v", tn, value, stale, ok, tt.value, tt.stale, tt.ok)
+				}
+			}
+		})
+
+		// the expiry must not be extended
+		fixTime(now.Add(1*time.Minute), func() {
+			if act := c.Contains("key"); act {
+				t.Errorf("Contains(%d); got %v, expected false", tn, act)
+			}
+		})
+	}
+}
+
+func TestCacheContains(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		key    string
+		access time.Time
+		exp    bool
+	}{
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			key:    "key",
+			access: now.Add(2 * time.Minute),
+			exp:    true,
+		},
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			key:    "other",
+			access: now,
+			exp:    false,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "key",
+			access: now.Add(30 * time.Second),
+			exp:    true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "key",
+			access: now.Add(2 * time.Minute),
+			exp:    false,
+		},
+		{
+			policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			key:    "key",
+			access: now.Add(30 * time.Second),
+			exp:    true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Policy:   tt.policy,
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", 1*time.Minute)
+		})
+
+		fixTime(tt.access, func() {
+			if act := c.Contains(tt.key); act != tt.exp {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+	}
+}
+
+func TestCacheContainsSideEffects(t *testing.T) {
+	now := time.Now().UTC()
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 2,
+		Policy:   lru.NewSlidingExpirationPolicy(1 * time.Minute),
+	})
+
+	c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+		evicted = append(evicted, i.Key)
+	}
+
+	fixTime(now, func() {
+		c.Set("key_1", 1, 1*time.Minute)
+		c.Set("key_2", 2, 1*time.Minute)
+	})
+
+	// contains must not slide the window
+	fixTime(now.Add(30*time.Second), func() {
+		c.Contains("key_1")
+	})
+	fixTime(now.Add(90*time.Second), func() {
+		if c.Contains("key_1") {
+			t.Errorf("Contains(); got true, expected false")
+		}
+	})
+
+	// contains must not promote recency
+	fixTime(now, func() {
+		c.Contains("key_1")
+		c.Set("key_3", 3, 1*time.Minute)
+	})
+	if len(evicted) != 1 || evicted[0] != "key_1" {
+		t.Errorf("Contains(); got %v evicted, expected [key_1]", evicted)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		ttl    time.Duration
+		key    string
+		access time.Duration
+		exp    time.Duration
+		ok     bool
+	}{
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    1 * time.Minute,
+			key:    "key",
+			access: 15 * time.Second,
+			exp:    45 * time.Second,
+			ok:     true,
+		},
+		{
+			policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			ttl:    1 * time.Minute,
+			key:    "key",
+			access: 15 * time.Second,
+			exp:    45 * time.Second,
+			ok:     true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    1 * time.Minute,
+			key:    "key",
+			access: 1 * time.Minute,
+			exp:    0,
+			ok:     false,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    0,
+			key:    "key",
+			access: 15 * time.Second,
+			exp:    0,
+			ok:     true,
+		},
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			ttl:    1 * time.Minute,
+			key:    "key",
+			access: 15 * time.Second,
+			exp:    0,
+			ok:     true,
+		},
+		{
+			policy: lru.NewAbsoluteExpirationPolicy(now.Add(2 * time.Minute)),
+			ttl:    1 * time.Minute,
+			key:    "key",
+			access: 15 * time.Second,
+			exp:    105 * time.Second,
+			ok:     true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			ttl:    1 * time.Minute,
+			key:    "other",
+			access: 15 * time.Second,
+			exp:    0,
+			ok:     false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: tt.policy,
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", tt.ttl)
+		})
+
+		fixTime(now.Add(tt.access), func() {
+			for idx := 0; idx < 2; idx++ {
+				act, ok := c.TTL(tt.key)
+				if act != tt.exp || ok != tt.ok {
+					t.Errorf("TTL(%d); got %v, %v, expected %v, %v", tn, act, ok, tt.exp, tt.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheTouch(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+		key    string
+		access time.Time
+		exp    bool
+		keys   []string
+	}{
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			key:    "key_1",
+			access: now.Add(2 * time.Minute),
+			exp:    true,
+			keys:   []string{"key_2", "key_1"},
+		},
+		{
+			policy: lru.NewNoExpirationPolicy(),
+			key:    "other",
+			access: now,
+			exp:    false,
+			keys:   []string{"key_1", "key_2"},
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			key:    "key_1",
+			access: now.Add(2 * time.Minute),
+			exp:    false,
+			keys:   []string{"key_2"},
+		},
+		{
+			policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+			key:    "key_1",
+			access: now.Add(30 * time.Second),
+			exp:    true,
+			keys:   []string{"key_2", "key_1"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Policy:   tt.policy,
+		})
+
+		fixTime(now, func() {
+			c.Set("key_1", "value_1", 1*time.Minute)
+			c.Set("key_2", "value_2", 1*time.Minute)
+		})
+
+		fixTime(tt.access, func() {
+			if act := c.Touch(tt.key); act != tt.exp {
+				t.Errorf("Touch(%d); got %v, expected %v", tn, act, tt.exp)
+			}
+		})
+
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheTouchSlidingExpiration(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+	})
+
+	fixTime(now, func() {
+		c.Set("key", "value", 1*time.Minute)
+	})
+
+	fixTime(now.Add(45*time.Second), func() {
+		c.Touch("key")
+	})
+
+	fixTime(now.Add(90*time.Second), func() {
+		if act := c.Contains("key"); !act {
+			t.Errorf("Contains(); got %v, expected true", act)
+		}
+	})
+}
+
+func TestCacheLen(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 2,
+		Policy:   lru.NewFixedExpirationPolicy(),
+	})
+
+	if act := c.Len(); act != 0 {
+		t.Errorf("Len(); got %d, expected 0", act)
+	}
+
+	fixTime(now, func() {
+		c.Set("key_1", 1, 1*time.Minute)
+		c.Set("key_2", 2, 1*time.Minute)
+		c.Set("key_3", 3, 1*time.Minute)
+	})
+	if act := c.Len(); act != 2 {
+		t.Errorf("Len(); got %d, expected 2", act)
+	}
+
+	// expired items are counted until removed
+	fixTime(now.Add(2*time.Minute), func() {
+		if act := c.Len(); act != 2 {
+			t.Errorf("Len(); got %d, expected 2", act)
+		}
+	})
+
+	c.Remove("key_2")
+	if act := c.Len(); act != 1 {
+		t.Errorf("Len(); got %d, expected 1", act)
+	}
+}
+
+func TestCacheCapacity(t *testing.T) {
+	tests := []struct {
+		options lru.Options
+		exp     int
+	}{
+		{
+			options: lru.Options{Capacity: 10},
+			exp:     10,
+		},
+		{
+			options: lru.Options{},
+			exp:     100,
+		},
+		{
+			options: lru.Options{Capacity: lru.Unlimited},
+			exp:     math.MaxInt,
+		},
+		{
+			options: lru.Options{MaxBytes: 1024},
+			exp:     math.MaxInt,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(tt.options)
+
+		if act := c.Capacity(); act != tt.exp {
+			t.Errorf("Capacity(%d); got %d, expected %d", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheResize(t *testing.T) {
+	tests := []struct {
+		capacity int
+		exp      int
+		evicted  int
+		keys     []string
+	}{
+		{
+			capacity: 5,
+			exp:      5,
+			evicted:  0,
+			keys:     []string{"key_0", "key_1", "key_2"},
+		},
+		{
+			capacity: 3,
+			exp:      3,
+			evicted:  0,
+			keys:     []string{"key_0", "key_1", "key_2"},
+		},
+		{
+			capacity: 1,
+			exp:      1,
+			evicted:  2,
+			keys:     []string{"key_2"},
+		},
+		{
+			capacity: 0,
+			exp:      100,
+			evicted:  0,
+			keys:     []string{"key_0", "key_1", "key_2"},
+		},
+		{
+			capacity: lru.Unlimited,
+			exp:      math.MaxInt,
+			evicted:  0,
+			keys:     []string{"key_0", "key_1", "key_2"},
+		},
+	}
+
+	for tn, tt := range tests {
+		var evicted []string
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			if r == lru.EvictCapacity {
+				evicted = append(evicted, i.Key)
+			}
+		}
+
+		for idx := 0; idx < 3; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+
+		if act := c.Resize(tt.capacity); act != tt.evicted {
+			t.Errorf("Resize(%d); got %d, expected %d", tn, act, tt.evicted)
+		}
+		if act := len(evicted); act != tt.evicted {
+			t.Errorf("ItemEvicted(%d); got %d, expected %d", tn, act, tt.evicted)
+		}
+		if act := c.Capacity(); act != tt.exp {
+			t.Errorf("Capacity(%d); got %d, expected %d", tn, act, tt.exp)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheCanEvict(t *testing.T) {
+	tests := []struct {
+		pinned  []string
+		evicted []string
+		keys    []string
+	}{
+		{
+			pinned:  nil,
+			evicted: []string{"key_0", "key_1"},
+			keys:    []string{"key_2", "key_3", "key_4"},
+		},
+		{
+			pinned:  []string{"key_0"},
+			evicted: []string{"key_1", "key_2"},
+			keys:    []string{"key_0", "key_3", "key_4"},
+		},
+		{
+			pinned:  []string{"key_0", "key_1", "key_2"},
+			evicted: []string{"key_3"},
+			keys:    []string{"key_0", "key_1", "key_2", "key_4"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+		})
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+		c.CanEvict = func(i *lru.Item) bool {
+			for _, k := range tt.pinned {
+				if i.Key == k {
+					return false
+				}
+			}
+			return true
+		}
+
+		for idx := 0; idx < 5; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+
+		act := c.Keys()
+		sort.Strings(act)
+		if fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCachePin(t *testing.T) {
+	tests := []struct {
+		pin     []string
+		unpin   []string
+		evicted []string
+	}{
+		{
+			pin:     []string{"key_0"},
+			evicted: []string{"key_1", "key_2"},
+		},
+		{
+			pin:     []string{"key_0", "key_1"},
+			unpin:   []string{"key_0"},
+			evicted: []string{"key_0", "key_2"},
+		},
+		{
+			pin:     []string{"key_0", "key_1", "key_2"},
+			evicted: []string{"key_3"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+		})
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		for idx := 0; idx < 3; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+
+		for _, k := range tt.pin {
+			if act := c.Pin(k); !act {
+				t.Errorf("Pin(%d); got %v, expected true", tn, act)
+			}
+		}
+		for _, k := range tt.unpin {
+			if act := c.Unpin(k); !act {
+				t.Errorf("Unpin(%d); got %v, expected true", tn, act)
+			}
+		}
+
+		c.Set("key_3", 3, 0)
+		c.Set("key_4", 4, 0)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+
+	now := time.Now().UTC()
+	c := lru.NewCache(lru.Options{
+		Policy:      lru.NewFixedExpirationPolicy(),
+		NegativeTTL: 1 * time.Hour,
+	})
+
+	fixTime(now, func() {
+		c.Set("expired", "value", 1*time.Minute)
+		c.GetOrAddFunc("negative", 0, func() (interface{}, error) {
+			return nil, errors.New("error")
+		})
+	})
+
+	fixTime(now.Add(2*time.Minute), func() {
+		for _, k := range []string{"other", "expired", "negative"} {
+			if act := c.Pin(k); act {
+				t.Errorf("Pin(%s); got %v, expected false", k, act)
+			}
+		}
+	})
+}
+
+func TestCacheWithUnlimitedCapacity(t *testing.T) {
+	var evicted int
+
+	c := lru.NewCache(lru.Options{
+		Capacity: lru.Unlimited,
+	})
+	c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+		evicted++
+	}
+
+	for idx := 0; idx < 1000; idx++ {
+		c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+	}
+
+	if act := c.Len(); act != 1000 {
+		t.Errorf("Len(); got %d, expected 1000", act)
+	}
+	if evicted != 0 {
+		t.Errorf("ItemEvicted(); got %d, expected 0", evicted)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Capacity: 3,
+	})
+
+	if act := c.Keys(); len(act) != 0 {
+		t.Errorf("Keys(); got %v, expected []", act)
+	}
+
+	c.Set("key_1", 1, 0)
+	c.Set("key_2", 2, 0)
+	c.Set("key_3", 3, 0)
+	c.Set("key_1", 4, 0)
+
+	exp := []string{"key_2", "key_3", "key_1"}
+	act := c.Keys()
+
+	if fmt.Sprint(act) != fmt.Sprint(exp) {
+		t.Errorf("Keys(); got %v, expected %v", act, exp)
+	}
+}
+
+func TestCacheValues(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewFixedExpirationPolicy(),
+	})
+
+	fixTime(now, func() {
+		c.Set("key_0", 0, 1*time.Minute)
+		c.Set("key_1", 1, 1*time.Second)
+		c.Set("key_2", 2, 0)
+		c.Touch("key_0")
+	})
+
+	fixTime(now.Add(30*time.Second), func() {
+		if act := c.Values(); fmt.Sprint(act) != "[2 0]" {
+			t.Errorf("Values(); got %v, expected [2 0]", act)
+		}
+	})
+}
+
+func TestCacheRange(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		limit int
+		exp   []string
+	}{
+		{
+			limit: 5,
+			exp:   []string{"key_0:0", "key_2:2"},
+		},
+		{
+			limit: 1,
+			exp:   []string{"key_0:0"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Policy:   lru.NewFixedExpirationPolicy(),
+		})
+
+		fixTime(now, func() {
+			c.Set("key_0", 0, 1*time.Minute)
+			c.Set("key_1", 1, 1*time.Second)
+			c.Set("key_2", 2, 1*time.Minute)
+		})
+
+		var act []string
+		fixTime(now.Add(30*time.Second), func() {
+			c.Range(func(k string, v interface{}) bool {
+				act = append(act, fmt.Sprintf("%s:%v", k, v))
+				return len(act) < tt.limit
+			})
+		})
+
+		if fmt.Sprint(act) != fmt.Sprint(tt.exp) {
+			t.Errorf("Range(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	now := time.Now().UTC()
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 3,
+		Policy:   lru.NewFixedExpirationPolicy(),
+	})
+
+	fixTime(now, func() {
+		c.Set("key_0", 0, 1*time.Minute)
+		c.Set("key_1", 1, 1*time.Second)
+		c.Set("key_2", 2, 0)
+		c.Touch("key_0")
+	})
+
+	var act []lru.Item
+	fixTime(now.Add(30*time.Second), func() {
+		act = c.Snapshot()
+	})
+
+	exp := []lru.Item{
+		{Key: "key_2", Value: 2, Created: now, LastAccess: now},
+		{Key: "key_0", Value: 0, Expires: now.Add(1 * time.Minute), Created: now, LastAccess: now},
+	}
+
+	if fmt.Sprint(act) != fmt.Sprint(exp) {
+		t.Errorf("Snapshot(); got %v, expected %v", act, exp)
+	}
+
+	// the lock is not held while the snapshot is processed
+	for _, i := range act {
+		c.Remove(i.Key)
+	}
+
+	fixTime(now.Add(30*time.Second), func() {
+		if act := c.Snapshot(); len(act) != 0 {
+			t.Errorf("Snapshot(); got %v, expected none", act)
+		}
+	})
+}
+
+func TestCacheRangeSharedAccess(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Eviction: lru.NewApproximateLRUEvictionPolicy,
+	})
+
+	for idx := 0; idx < 10; idx++ {
+		c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+	}
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < 4; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				c.GetOrAddFunc(fmt.Sprintf("key_%d", n%10), 0, func() (interface{}, error) {
+					return nil, nil
+				})
+			}
+		}()
+	}
+
+	for n := 0; n < 100; n++ {
+		c.Range(func(string, interface{}) bool { return true })
+		c.Snapshot()
+		c.Values()
+	}
+
+	wg.Wait()
+}
+
+func TestCacheRemove(t *testing.T) {
+	tests := []struct {
+		keys    []string
+		key     string
+		exp     bool
+		evicted int
+	}{
+		{
+			keys:    []string{"key_1", "key_2"},
+			key:     "key_1",
+			exp:     true,
+			evicted: 1,
+		},
+		{
+			keys:    []string{"key_1", "key_2"},
+			key:     "key_3",
+			exp:     false,
+			evicted: 0,
+		},
+		{
+			keys:    []string{},
+			key:     "key_1",
+			exp:     false,
+			evicted: 0,
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := 0
+
+		c := lru.NewCache(lru.Options{})
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			evicted++
+		}
+
+		for _, k := range tt.keys {
+			c.Set(k, k, 0)
+		}
+
+		if act := c.Remove(tt.key); act != tt.exp {
+			t.Errorf("Remove(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+		if evicted != tt.evicted {
+			t.Errorf("Remove(%d); got %d evictions, expected %d", tn, evicted, tt.evicted)
+		}
+		if c.Remove(tt.key) {
+			t.Errorf("Remove(%d); got true on second call, expected false", tn)
+		}
+	}
+}
+
+func TestCacheTake(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		key     string
+		exp     interface{}
+		ok      bool
+		evicted []string
+	}{
+		{
+			key:     "key_1",
+			exp:     1,
+			ok:      true,
+			evicted: []string{"key_1:removed"},
+		},
+		{
+			key:     "key_2",
+			exp:     nil,
+			ok:      false,
+			evicted: []string{"key_2:expired"},
+		},
+		{
+			key:     "key_3",
+			exp:     nil,
+			ok:      false,
+			evicted: []string{},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewFixedExpirationPolicy(),
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		fixTime(now, func() {
+			c.Set("key_1", 1, 0)
+			c.Set("key_2", 2, 1*time.Second)
+		})
+
+		fixTime(now.Add(1*time.Minute), func() {
+			act, ok := c.Take(tt.key)
+			if act != tt.exp || ok != tt.ok {
+				t.Errorf("Take(%d); got %v, %v, expected %v, %v", tn, act, ok, tt.exp, tt.ok)
+			}
+			if _, ok := c.Take(tt.key); ok {
+				t.Errorf("Take(%d); got true on second call, expected false", tn)
+			}
+		})
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
+
+func TestCacheTakeConcurrent(t *testing.T) {
+	c := lru.NewCache(lru.Options{})
+	c.Set("key", "value", 0)
+
+	var n atomic.Int32
+	var wg sync.WaitGroup
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := c.Take("key"); ok {
+				n.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if act := n.Load(); act != 1 {
+		t.Errorf("Take(); got %d values, expected 1", act)
+	}
+}
+
+func TestCacheRemoveFunc(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		prefix  string
+		exp     int
+		evicted []string
+		keys    []string
+	}{
+		{
+			prefix:  "a:",
+			exp:     2,
+			evicted: []string{"a:1:removed", "a:3:removed"},
+			keys:    []string{"b:2", "a:4"},
+		},
+		{
+			prefix:  "c:",
+			exp:     0,
+			evicted: []string{},
+			keys:    []string{"a:1", "b:2", "a:3", "a:4"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewFixedExpirationPolicy(),
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		fixTime(now, func() {
+			c.Set("a:1", 1, 0)
+			c.Set("b:2", 2, 0)
+			c.Set("a:3", 3, 0)
+			c.Set("a:4", 4, 1*time.Second)
+		})
+
+		fixTime(now.Add(1*time.Minute), func() {
+			act := c.RemoveFunc(func(k string, _ interface{}) bool {
+				return strings.HasPrefix(k, tt.prefix)
+			})
+			if act != tt.exp {
+				t.Errorf("RemoveFunc(%d); got %d, expected %d", tn, act, tt.exp)
+			}
+		})
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheCreatePanic(t *testing.T) {
+	tests := []struct {
+		opts lru.Options
+		fn   func(c *lru.Cache)
+	}{
+		{
+			opts: lru.Options{},
+			fn: func(c *lru.Cache) {
+				c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+					panic("create")
+				})
+			},
+		},
+		{
+			opts: lru.Options{},
+			fn: func(c *lru.Cache) {
+				c.Get("key", 0)
+			},
+		},
+		{
+			opts: lru.Options{},
+			fn: func(c *lru.Cache) {
+				c.GetOrAdd(&lru.GetOrAdd{
+					Key: "key",
+					CreateContext: func(context.Context) (interface{}, error) {
+						panic("create")
+					},
+				})
+			},
+		},
+		{
+			opts: lru.Options{CreateTimeout: 1 * time.Second},
+			fn: func(c *lru.Cache) {
+				c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+					panic("create")
+				})
+			},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(tt.opts)
+
+		func() {
+			defer func() {
+				if p := recover(); p == nil {
+					t.Errorf("GetOrAdd(%d); got nil, expected panic", tn)
+				}
+			}()
+
+			tt.fn(c)
+		}()
+
+		act, err := c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+			return "value", nil
+		})
+		if err != nil || act != "value" {
+			t.Errorf("GetOrAddFunc(%d); got %v, %v, expected value, nil", tn, act, err)
+		}
+	}
+}
+
+func TestCacheRecoverCreate(t *testing.T) {
+	tests := []struct {
+		opts lru.Options
+		req  lru.GetOrAdd
+	}{
+		{
+			opts: lru.Options{RecoverCreate: true},
+			req: lru.GetOrAdd{
+				Create: func() (interface{}, error) {
+					panic("create")
+				},
+			},
+		},
+		{
+			opts: lru.Options{RecoverCreate: true},
+			req: lru.GetOrAdd{
+				CreateContext: func(context.Context) (interface{}, error) {
+					panic("create")
+				},
+			},
+		},
+		{
+			opts: lru.Options{RecoverCreate: true, CreateTimeout: 1 * time.Second},
+			req: lru.GetOrAdd{
+				Create: func() (interface{}, error) {
+					var m map[string]int
+					m["key"]++
+					return nil, nil
+				},
+			},
+		},
+		{
+			opts: lru.Options{RecoverCreate: true, Loader: func(string) (interface{}, error) {
+				panic("loader")
+			}},
+			req: lru.GetOrAdd{},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(tt.opts)
+
+		r := tt.req
+		r.Key = "key"
+		if err := c.GetOrAdd(&r); !errors.Is(err, lru.ErrCreatePanic) {
+			t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, lru.ErrCreatePanic)
+		}
+
+		act, err := c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+			return "value", nil
+		})
+		if act != "value" || err != nil {
+			t.Errorf("GetOrAddFunc(%d); got %v, %v, expected value, nil", tn, act, err)
+		}
+		if err := c.CheckInvariants(); err != nil {
+			t.Errorf("CheckInvariants(%d); got %v, expected nil", tn, err)
+		}
+	}
+}
+
+func TestCacheGetOrAddForce(t *testing.T) {
+	tests := []struct {
+		create  func() (interface{}, error)
+		result  interface{}
+		err     bool
+		value   interface{}
+		evicted []string
+	}{
+		{
+			create: func() (interface{}, error) {
+				return "new", nil
+			},
+			result:  "new",
+			err:     false,
+			value:   "new",
+			evicted: []string{"a:replaced", "b:capacity"},
+		},
+		{
+			create: func() (interface{}, error) {
+				return nil, errors.New("error")
+			},
+			result:  nil,
+			err:     true,
+			value:   "old",
+			evicted: []string{"a:capacity"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Policy:   lru.NewFixedExpirationPolicy(),
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		c.Set("a", "old", 1*time.Minute)
+		c.Set("b", "old", 1*time.Minute)
+
+		r := lru.GetOrAdd{Key: "a", TTL: 1 * time.Minute, Create: tt.create, Force: true}
+		err := c.GetOrAdd(&r)
+		if (err != nil) != tt.err {
+			t.Errorf("GetOrAdd(%d); got %v, expected error %v", tn, err, tt.err)
+		}
+		if r.Result != tt.result || r.Created != true {
+			t.Errorf("GetOrAdd(%d); got %v, %v, expected %v, true", tn, r.Result, r.Created, tt.result)
+		}
+
+		if act, _, _ := c.GetStale("a"); act != tt.value {
+			t.Errorf("GetStale(%d); got %v, expected %v", tn, act, tt.value)
+		}
+
+		// the replaced item is the most recently used
+		c.Set("c", "old", 0)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	errLoad := errors.New("error")
+
+	found := func(v string) func(string) (interface{}, bool, error) {
+		return func(string) (interface{}, bool, error) {
+			return v, true, nil
+		}
+	}
+	missing := func(string) (interface{}, bool, error) {
+		return nil, false, nil
+	}
+	failed := func(string) (interface{}, bool, error) {
+		return nil, false, errLoad
+	}
+
+	tests := []struct {
+		loaders []func(string) (interface{}, bool, error)
+		exp     interface{}
+		err     error
+		cached  bool
+	}{
+		{
+			loaders: []func(string) (interface{}, bool, error){found("a"), found("b")},
+			exp:     "a",
+			cached:  true,
+		},
+		{
+			loaders: []func(string) (interface{}, bool, error){missing, missing, found("c")},
+			exp:     "c",
+			cached:  true,
+		},
+		{
+			loaders: []func(string) (interface{}, bool, error){missing, failed, found("c")},
+			exp:     nil,
+			err:     errLoad,
+			cached:  false,
+		},
+		{
+			loaders: []func(string) (interface{}, bool, error){missing, missing},
+			exp:     nil,
+			err:     lru.ErrNotFound,
+			cached:  false,
+		},
+		{
+			loaders: nil,
+			exp:     nil,
+			err:     lru.ErrNotFound,
+			cached:  false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{})
+
+		act, err := c.GetOrLoad("key", 0, tt.loaders...)
+		if act != tt.exp || err != tt.err {
+			t.Errorf("GetOrLoad(%d); got %v, %v, expected %v, %v", tn, act, err, tt.exp, tt.err)
+		}
+		if act := c.Contains("key"); act != tt.cached {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.cached)
+		}
+	}
+}
+
+func TestCacheRejectEmptyKeys(t *testing.T) {
+	tests := []struct {
+		reject   bool
+		err      error
+		calls    int
+		len      int
+		contains bool
+	}{
+		{reject: false, err: nil, calls: 1, len: 3, contains: true},
+		{reject: true, err: lru.ErrEmptyKey, calls: 0, len: 2, contains: false},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{RejectEmptyKeys: tt.reject})
+
+		var calls int
+		for idx := 0; idx < 2; idx++ {
+			_, err := c.GetOrAddFunc("", 0, func() (interface{}, error) {
+				calls++
+				return "value", nil
+			})
+			if err != tt.err {
+				t.Errorf("GetOrAddFunc(%d); got %v, expected %v", tn, err, tt.err)
+			}
+		}
+		if calls != tt.calls {
+			t.Errorf("GetOrAddFunc(%d); got %d create calls, expected %d", tn, calls, tt.calls)
+		}
+
+		c.Set("", "value", 0)
+		c.SetMulti([]lru.Item{{Key: ""}, {Key: "key"}}, 0)
+
+		src := lru.NewCache(lru.Options{})
+		src.Set("", "value", 0)
+		src.Set("saved", "value", 0)
+
+		var buf bytes.Buffer
+		if err := src.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Load(&buf); err != nil {
+			t.Errorf("Load(%d); got %v, expected nil", tn, err)
+		}
+
+		if act := c.Len(); act != tt.len {
+			t.Errorf("Len(%d); got %d, expected %d", tn, act, tt.len)
+		}
+		if act := c.Contains(""); act != tt.contains {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.contains)
+		}
+	}
+}
+
+func TestCacheGetOrAddOnce(t *testing.T) {
+	now := time.Now().UTC()
+
+	var calls int
+	create := func() (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("error")
+		}
+
+		return calls, nil
+	}
+
+	c := lru.NewCache(lru.Options{
+		Capacity:    2,
+		Policy:      lru.NewAbsoluteExpirationPolicy(now.Add(1 * time.Minute)),
+		NegativeTTL: 1 * time.Minute,
+	})
+
+	fixTime(now, func() {
+		if _, err := c.GetOrAddOnce("a", create); err == nil {
+			t.Errorf("GetOrAddOnce(); got nil, expected an error")
+		}
+		if act, err := c.GetOrAddOnce("a", create); act != 2 || err != nil {
+			t.Errorf("GetOrAddOnce(); got %v, %v, expected 2, nil", act, err)
+		}
+
+		c.Set("b", 1, 0)
+		c.Set("c", 1, 0)
+		c.Set("d", 1, 0)
+	})
+
+	fixTime(now.Add(1*time.Hour), func() {
+		if act, err := c.GetOrAddOnce("a", create); act != 2 || err != nil {
+			t.Errorf("GetOrAddOnce(); got %v, %v, expected 2, nil", act, err)
+		}
+	})
+
+	if calls != 2 {
+		t.Errorf("GetOrAddOnce(); got %d create calls, expected 2", calls)
+	}
+}
+
+func TestCacheOnStore(t *testing.T) {
+	errInvalid := errors.New("invalid")
+
+	tests := []struct {
+		key    string
+		value  string
+		result interface{}
+		err    error
+		cached bool
+	}{
+		{key: "key_1", value: "value", result: "VALUE", err: nil, cached: true},
+		{key: "key_2", value: "", result: nil, err: errInvalid, cached: false},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{})
+		c.OnStore = func(k string, v interface{}) (interface{}, error) {
+			if v == "" {
+				return nil, errInvalid
+			}
+
+			return strings.ToUpper(v.(string)), nil
+		}
+
+		act, err := c.GetOrAddFunc(tt.key, 0, func() (interface{}, error) {
+			return tt.value, nil
+		})
+		if act != tt.result || err != tt.err {
+			t.Errorf("GetOrAddFunc(%d); got %v, %v, expected %v, %v", tn, act, err, tt.result, tt.err)
+		}
+		if act := c.Contains(tt.key); act != tt.cached {
+			t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.cached)
+		}
+	}
+}
+
+func TestBytesCache(t *testing.T) {
+	tests := []struct {
+		opts    lru.BytesOptions
+		evicted []string
+		bytes   int64
+	}{
+		{
+			opts:    lru.BytesOptions{Capacity: lru.Unlimited, MaxBytes: 8},
+			evicted: []string{"a"},
+			bytes:   7,
+		},
+		{
+			opts: lru.BytesOptions{
+				Capacity: lru.Unlimited,
+				MaxBytes: 8,
+				Sizer:    func([]byte) int64 { return 1 },
+			},
+			evicted: []string{},
+			bytes:   3,
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewBytesCache(tt.opts)
+		c.ItemEvicted = func(i *lru.TypedItem[string, []byte], _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		c.Set("a", []byte("abc"), 0)
+		c.Set("b", []byte("de"), 0)
+
+		r := lru.BytesGetOrAdd{
+			Key: "c",
+			Create: func() ([]byte, error) {
+				return []byte("fghij"), nil
+			},
+		}
+		if err := c.GetOrAdd(&r); err != nil {
+			t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+		}
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Stats().Bytes; act != tt.bytes {
+			t.Errorf("Stats(%d); got %d bytes, expected %d", tn, act, tt.bytes)
+		}
+	}
+}
+
+func TestCacheDisableLRU(t *testing.T) {
+	tests := []struct {
+		opts    lru.Options
+		evicted []string
+	}{
+		{
+			opts:    lru.Options{Capacity: 2},
+			evicted: []string{"b"},
+		},
+		{
+			opts:    lru.Options{Capacity: 2, DisableLRU: true},
+			evicted: []string{"a"},
+		},
+		{
+			opts:    lru.Options{Capacity: 2, DisableLRU: true, Eviction: lru.NewLRUEvictionPolicy},
+			evicted: []string{"b"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(tt.opts)
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		c.Set("a", 1, 0)
+		c.Set("b", 2, 0)
+		c.GetOrAddFunc("a", 0, func() (interface{}, error) {
+			return nil, errors.New("error")
+		})
+		c.Set("c", 3, 0)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("Set(%d); got %v evicted, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
+
+func TestCacheString(t *testing.T) {
+	tests := []struct {
+		opts lru.Options
+		exp  string
+	}{
+		{
+			opts: lru.Options{},
+			exp:  "lru.Cache{len: 2, capacity: 100, policy: *lru.TypedNoExpirationPolicy[string,interface {}]}",
+		},
+		{
+			opts: lru.Options{Capacity: 10, Policy: lru.NewFixedExpirationPolicy()},
+			exp:  "lru.Cache{len: 2, capacity: 10, policy: *lru.TypedFixedExpirationPolicy[string,interface {}]}",
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(tt.opts)
+		c.Set("a", 1, 0)
+		c.Set("b", 2, 0)
+
+		if act := c.String(); act != tt.exp {
+			t.Errorf("String(%d); got %s, expected %s", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheDump(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewFixedExpirationPolicy(),
+	})
+
+	fixTime(now, func() {
+		c.Set("a", 1, 1*time.Minute)
+		c.Set("b", 2, 0)
+		c.Set("c", 3, 1*time.Second)
+		c.Touch("a")
+	})
+
+	exp := "b\t0001-01-01T00:00:00Z\nc\t2024-01-02T03:04:06Z\na\t2024-01-02T03:05:05Z\n"
+	if act := c.Dump(); act != exp {
+		t.Errorf("Dump(); got %q, expected %q", act, exp)
+	}
+}
+
+func TestCacheInvalidateTag(t *testing.T) {
+	tests := []struct {
+		fn      func(c *lru.Cache)
+		tag     string
+		exp     int
+		evicted []string
+		keys    []string
+	}{
+		{
+			fn:      func(c *lru.Cache) {},
+			tag:     "user:1",
+			exp:     2,
+			evicted: []string{"a:removed", "c:removed"},
+			keys:    []string{"b", "d"},
+		},
+		{
+			fn:      func(c *lru.Cache) {},
+			tag:     "user:2",
+			exp:     2,
+			evicted: []string{"b:removed", "c:removed"},
+			keys:    []string{"a", "d"},
+		},
+		{
+			fn:      func(c *lru.Cache) {},
+			tag:     "user:3",
+			exp:     0,
+			evicted: []string{},
+			keys:    []string{"a", "b", "c", "d"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Set("a", 1, 0)
+			},
+			tag:     "user:1",
+			exp:     1,
+			evicted: []string{"c:removed"},
+			keys:    []string{"b", "d", "a"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Remove("c")
+			},
+			tag:     "user:2",
+			exp:     1,
+			evicted: []string{"b:removed"},
+			keys:    []string{"a", "d"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Resize(3)
+			},
+			tag:     "user:1",
+			exp:     1,
+			evicted: []string{"c:removed"},
+			keys:    []string{"b", "d"},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{})
+
+		add := func(key string, tags ...string) {
+			c.GetOrAdd(&lru.GetOrAdd{
+				Key:  key,
+				Tags: tags,
+				Create: func() (interface{}, error) {
+					return key, nil
+				},
+			})
+		}
+
+		add("a", "user:1")
+		add("b", "user:2")
+		add("c", "user:1", "user:2")
+		add("d")
+
+		tt.fn(c)
+
+		evicted := []string{}
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		if act := c.InvalidateTag(tt.tag); act != tt.exp {
+			t.Errorf("InvalidateTag(%d); got %d, expected %d", tn, act, tt.exp)
+		}
+
+		sort.Strings(evicted)
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+		if act := c.InvalidateTag(tt.tag); act != 0 {
+			t.Errorf("InvalidateTag(%d); got %d, expected 0", tn, act)
+		}
+	}
+}
+
+func TestCacheRemoveExpired(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		offset  time.Duration
+		exp     int
+		evicted []string
+		keys    []string
+	}{
+		{
+			offset:  30 * time.Second,
+			exp:     1,
+			evicted: []string{"a:expired"},
+			keys:    []string{"b", "c"},
+		},
+		{
+			offset:  61 * time.Second,
+			exp:     2,
+			evicted: []string{"a:expired", "b:expired"},
+			keys:    []string{"c"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewSlidingExpirationPolicy(1 * time.Minute),
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+
+		fixTime(now, func() {
+			c.Set("a", 1, 10*time.Second)
+			c.Set("b", 2, 1*time.Minute)
+			c.Set("c", 3, 0)
+		})
+
+		// sweep twice to ensure that the first sweep does not slide the expiry
+		fixTime(now.Add(5*time.Second), func() {
+			c.RemoveExpired()
+		})
+		evicted = []string{}
+
+		fixTime(now.Add(tt.offset), func() {
+			if act := c.RemoveExpired(); act != tt.exp {
+				t.Errorf("RemoveExpired(%d); got %d, expected %d", tn, act, tt.exp)
+			}
+		})
+
+		sort.Strings(evicted)
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestCacheItemsEvicted(t *testing.T) {
+	tests := []struct {
+		fn  func(c *lru.Cache)
+		exp []string
+	}{
+		{
+			fn: func(c *lru.Cache) {
+				c.Clear()
+			},
+			exp: []string{"[key_0 key_1 key_2]:removed"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Resize(1)
+			},
+			exp: []string{"[key_0 key_1]:capacity"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Remove("key_1")
+				c.Set("key_3", 3, 0)
+			},
+			exp: []string{"key_1:removed"},
+		},
+		{
+			fn: func(c *lru.Cache) {
+				c.Resize(3)
+			},
+			exp: []string{},
+		},
+	}
+
+	for tn, tt := range tests {
+		act := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+		})
+		c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+			if r == lru.EvictCapacity {
+				return
+			}
+
+			act = append(act, fmt.Sprintf("%s:%s", i.Key, r))
+		}
+		c.ItemsEvicted = func(is []*lru.Item, r lru.EvictReason) {
+			keys := make([]string, len(is))
+			for idx, i := range is {
+				keys[idx] = i.Key
+			}
+
+			act = append(act, fmt.Sprintf("%v:%s", keys, r))
+		}
+
+		for idx := 0; idx < 3; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+
+		tt.fn(c)
+
+		if fmt.Sprint(act) != fmt.Sprint(tt.exp) {
+			t.Errorf("ItemsEvicted(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheItemsEvictedWithReapInterval(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Now().UnixNano())
+
+	evicted := make(chan int, 10)
+
+	c := lru.NewCache(lru.Options{
+		Policy:       lru.NewFixedExpirationPolicy(),
+		ReapInterval: 5 * time.Millisecond,
+		Clock: lru.ClockFunc(func() time.Time {
+			return time.Unix(0, now.Load()).UTC()
+		}),
+	})
+	defer c.Close()
+
+	c.ItemsEvicted = func(is []*lru.Item, r lru.EvictReason) {
+		if r == lru.EvictExpired {
+			evicted <- len(is)
+		}
+	}
+
+	for idx := 0; idx < 3; idx++ {
+		c.Set(fmt.Sprintf("key_%d", idx), idx, 1*time.Minute)
+	}
+
+	now.Add(int64(90 * time.Second))
+
+	select {
+	case act := <-evicted:
+		if act != 3 {
+			t.Errorf("ItemsEvicted(); got %d items, expected 3", act)
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("ItemsEvicted(); got no invocation, expected 3 items")
+	}
+}
+
+func TestCacheWithUnlockedEvicted(t *testing.T) {
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity:        2,
+		UnlockedEvicted: true,
+	})
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		// cache methods must not deadlock
+		evicted = append(evicted, fmt.Sprintf("%s:%s:%v", i.Key, r, c.Contains(i.Key)))
+	}
+	c.ItemsEvicted = func(is []*lru.Item, r lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%d:%s:%d", len(is), r, c.Len()))
+	}
+
+	c.Set("key_1", 1, 0)
+	c.Set("key_2", 2, 0)
+	c.Set("key_3", 3, 0)
+	c.Set("key_3", 4, 0)
+	c.Remove("key_2")
+
+	if _, err := c.GetOrAddFunc("key_4", 0, func() (interface{}, error) {
+		return 4, nil
+	}); err != nil {
+		t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+	}
+
+	c.Clear()
+
+	exp := []string{
+		"key_1:capacity:false",
+		"key_3:replaced:true",
+		"key_2:removed:false",
+		"2:removed:0",
+	}
+
+	if fmt.Sprint(evicted) != fmt.Sprint(exp) {
+		t.Errorf("ItemEvicted(); got %v, expected %v", evicted, exp)
+	}
+}
+
+func TestCacheItemAdded(t *testing.T) {
+	added := []string{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 2,
+	})
+	c.ItemAdded = func(i *lru.Item) {
+		added = append(added, fmt.Sprintf("%s:%v", i.Key, i.Value))
+	}
+
+	for _, k := range []string{"key_1", "key_1", "key_2"} {
+		req := lru.GetOrAdd{
+			Key: k,
+			Create: func() (interface{}, error) {
+				return k, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+	}
+
+	c.Set("key_2", "value", 0)
+	c.Set("key_3", "value", 0)
+
+	exp := []string{"key_1:key_1", "key_2:key_2", "key_3:value"}
+	if fmt.Sprint(added) != fmt.Sprint(exp) {
+		t.Errorf("ItemAdded(); got %v, expected %v", added, exp)
+	}
+}
+
+func TestCacheEvictReason(t *testing.T) {
+	reasons := map[string]lru.EvictReason{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 2,
+	})
+
+	c.ItemEvicted = func(i *lru.Item, r lru.EvictReason) {
+		reasons[fmt.Sprintf("%s:%v", i.Key, i.Value)] = r
+	}
+
+	c.Set("key_1", 1, 0)
+	c.Set("key_2", 2, 0)
+	c.Set("key_2", 3, 0)
+	c.Set("key_3", 4, 0)
+	c.Remove("key_2")
+	c.Clear()
+
+	exp := map[string]lru.EvictReason{
+		"key_2:2": lru.EvictReplaced,
+		"key_1:1": lru.EvictCapacity,
+		"key_2:3": lru.EvictRemoved,
+		"key_3:4": lru.EvictRemoved,
+	}
+
+	if fmt.Sprint(reasons) != fmt.Sprint(exp) {
+		t.Errorf("ItemEvicted(); got %v, expected %v", reasons, exp)
+	}
+}
+
+func TestCacheClear(t *testing.T) {
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{})
+	c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+		evicted = append(evicted, i.Key)
+	}
+
+	c.Set("key_1", 1, 0)
+	c.Set("key_2", 2, 0)
+	c.Clear()
+
+	if act := c.Len(); act != 0 {
+		t.Errorf("Clear(); got %d items, expected 0", act)
+	}
+	if len(evicted) != 2 || evicted[0] != "key_1" || evicted[1] != "key_2" {
+		t.Errorf("Clear(); got %v evicted, expected [key_1 key_2]", evicted)
+	}
+	if c.Contains("key_1") {
+		t.Errorf("Contains(); got true, expected false")
+	}
+}
+
+func TestCacheAutoCloseValues(t *testing.T) {
+	tests := []struct {
+		autoClose bool
+		unlocked  bool
+		fn        func(c *lru.TypedCache[string, *testCloser], v *testCloser)
+		exp       bool
+	}{
+		{
+			autoClose: false,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Remove("key_1")
+			},
+			exp: false,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Remove("key_1")
+			},
+			exp: true,
+		},
+		{
+			autoClose: true,
+			unlocked:  true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Remove("key_1")
+			},
+			exp: true,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Set("key_2", &testCloser{}, 0)
+				c.Set("key_3", &testCloser{}, 0)
+			},
+			exp: true,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Set("key_1", &testCloser{}, 0)
+			},
+			exp: true,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Set("key_1", v, 0)
+			},
+			exp: false,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Clear()
+			},
+			exp: true,
+		},
+		{
+			autoClose: true,
+			fn: func(c *lru.TypedCache[string, *testCloser], v *testCloser) {
+				c.Take("key_1")
+			},
+			exp: false,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewTypedCache(lru.TypedOptions[string, *testCloser]{
+			Capacity:        2,
+			AutoCloseValues: tt.autoClose,
+			UnlockedEvicted: tt.unlocked,
+		})
+
+		v := &testCloser{}
+		c.Set("key_1", v, 0)
+		tt.fn(c, v)
+
+		if act := v.closed.Load() > 0; act != tt.exp {
+			t.Errorf("Close(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+		if act := v.closed.Load(); act > 1 {
+			t.Errorf("Close(%d); got %d calls, expected 1", tn, act)
+		}
+	}
+}
+
+func TestCacheAutoCloseUncomparableValues(t *testing.T) {
+	tests := []struct {
+		data any
+		exp  int32
+	}{
+		{data: 1, exp: 0},
+		{data: []byte("value"), exp: 1},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewTypedCache(lru.TypedOptions[string, testDataCloser]{
+			AutoCloseValues: true,
+		})
+
+		v := testDataCloser{data: tt.data, closed: &atomic.Int32{}}
+		c.Set("key", v, 0)
+		c.Set("key", v, 0)
+
+		if act := v.closed.Load(); act != tt.exp {
+			t.Errorf("Close(%d); got %d calls, expected %d", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheOnCloseError(t *testing.T) {
+	closeErr := errors.New("error")
+
+	c := lru.NewTypedCache(lru.TypedOptions[string, *testCloser]{
+		AutoCloseValues: true,
+	})
+
+	var key string
+	var err error
+	c.OnCloseError = func(k string, e error) {
+		key, err = k, e
+	}
+
+	c.Set("key", &testCloser{err: closeErr}, 0)
+	c.Remove("key")
+
+	if key != "key" || err != closeErr {
+		t.Errorf("OnCloseError(); got %s, %v, expected key, %v", key, err, closeErr)
+	}
+}
+
+type testCloser struct {
+	closed atomic.Int32
+	err    error
+}
+
+type testDataCloser struct {
+	data   any
+	closed *atomic.Int32
+}
+
+func (c testDataCloser) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func (c *testCloser) Close() error {
+	c.closed.Add(1)
+	return c.err
 }
 
 func fixTime(t time.Time, fn func()) {
@@ -0,0 +1,68 @@
+package lru
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Cacher represents the operations supported by Cache and ShardedCache
+type Cacher = TypedCacher[string, interface{}]
+
+var (
+	_ TypedCacher[string, interface{}] = (*TypedCache[string, interface{}])(nil)
+	_ TypedCacher[string, interface{}] = (*TypedShardedCache[string, interface{}])(nil)
+)
+
+// TypedCacher represents the operations supported by TypedCache and TypedShardedCache.
+// It allows the cache to be replaced with a fake in tests. The event funcs, such as
+// ItemEvicted, are fields and are not part of the interface.
+type TypedCacher[K comparable, V any] interface {
+	GetOrAdd(r *TypedGetOrAdd[K, V]) error
+	GetOrAddFunc(key K, ttl time.Duration, create func() (V, error)) (V, error)
+	GetOrAddWithTTLFunc(key K, create func() (V, time.Duration, error)) (V, error)
+	GetOrAddOnce(key K, create func() (V, error)) (V, error)
+	GetOrLoad(key K, ttl time.Duration, loaders ...func(K) (V, bool, error)) (V, error)
+	GetOrAddContext(ctx context.Context, r *TypedGetOrAdd[K, V]) error
+	Get(key K, ttl time.Duration) (V, error)
+	GetMulti(keys []K) map[K]V
+	GetStale(key K) (value V, stale bool, ok bool)
+	Warm(keys []K, loader func(K) (V, error), ttl time.Duration) error
+
+	Set(key K, value V, ttl time.Duration) (V, bool)
+	SetMulti(items []TypedItem[K, V], ttl time.Duration)
+	UpdateValue(key K, value V) bool
+	CompareAndSwap(key K, old, new V) bool
+	SetPolicy(p TypedExpirationPolicy[K, V])
+
+	Contains(key K) bool
+	TTL(key K) (time.Duration, bool)
+	Touch(key K) bool
+	Pin(key K) bool
+	Unpin(key K) bool
+
+	Remove(key K) bool
+	Take(key K) (V, bool)
+	RemoveFunc(fn func(key K, value V) bool) int
+	RemoveExpired() int
+	InvalidateTag(tag string) int
+	Clear()
+
+	Len() int
+	Capacity() int
+	Resize(capacity int) int
+	Keys() []K
+	Values() []V
+	Range(fn func(key K, value V) bool)
+	Snapshot() []TypedItem[K, V]
+	String() string
+	Dump() string
+	CheckInvariants() error
+
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+
+	Stats() Stats
+	ResetStats()
+	Close() error
+}
@@ -0,0 +1,815 @@
+package lru
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+type (
+	// EvictionPolicy represents a cache eviction policy
+	EvictionPolicy = TypedEvictionPolicy[string, interface{}]
+
+	// LRUEvictionPolicy represents a least recently used eviction policy
+	LRUEvictionPolicy = TypedLRUEvictionPolicy[string, interface{}]
+
+	// ApproximateLRUEvictionPolicy represents an approximate least recently used eviction policy
+	ApproximateLRUEvictionPolicy = TypedApproximateLRUEvictionPolicy[string, interface{}]
+
+	// LFUEvictionPolicy represents a least frequently used eviction policy
+	LFUEvictionPolicy = TypedLFUEvictionPolicy[string, interface{}]
+
+	// TwoQueueEvictionPolicy represents a 2Q eviction policy
+	TwoQueueEvictionPolicy = TypedTwoQueueEvictionPolicy[string, interface{}]
+
+	// ARCEvictionPolicy represents an adaptive replacement cache eviction policy
+	ARCEvictionPolicy = TypedARCEvictionPolicy[string, interface{}]
+
+	// SampledEvictionPolicy represents a sampled least recently used eviction policy
+	SampledEvictionPolicy = TypedSampledEvictionPolicy[string, interface{}]
+
+	// FIFOEvictionPolicy represents a first in, first out eviction policy
+	FIFOEvictionPolicy = TypedFIFOEvictionPolicy[string, interface{}]
+)
+
+// TypedEvictionPolicy represents a typed cache eviction policy. The policy tracks
+// the cached items and selects the item to evict when the cache is full.
+// Methods are invoked while the cache lock is held.
+type TypedEvictionPolicy[K comparable, V any] interface {
+	// Add starts tracking the item
+	Add(*TypedItem[K, V])
+
+	// Remove stops tracking the item
+	Remove(*TypedItem[K, V])
+
+	// RecordAccess records an access for the item
+	RecordAccess(*TypedItem[K, V])
+
+	// Evict stops tracking and returns the item that should be evicted, or nil
+	// if no items are tracked
+	Evict() *TypedItem[K, V]
+
+	// Range invokes the func for each tracked item in eviction order until the
+	// func returns false
+	Range(func(*TypedItem[K, V]) bool)
+}
+
+// randomEvictionPolicy is implemented by eviction policies that use the cache random
+// source. The source is nil if the package source is used.
+type randomEvictionPolicy interface {
+	useRand(*rand.Rand)
+}
+
+// skippingEvictionPolicy is implemented by eviction policies that can skip items that
+// cannot be evicted without affecting their eviction state. evictFunc stops tracking and
+// returns the item that should be evicted for which the func returns true, or nil if
+// there is no such item.
+type skippingEvictionPolicy[K comparable, V any] interface {
+	evictFunc(func(*TypedItem[K, V]) bool) *TypedItem[K, V]
+}
+
+// evictAny is the func used by Evict, which does not skip any items
+func evictAny[K comparable, V any](*TypedItem[K, V]) bool {
+	return true
+}
+
+// NewLRUEvictionPolicy returns a new LRUEvictionPolicy
+func NewLRUEvictionPolicy() EvictionPolicy {
+	return NewTypedLRUEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedLRUEvictionPolicy returns a new TypedLRUEvictionPolicy
+func NewTypedLRUEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedLRUEvictionPolicy[K, V]{list: list.New()}
+}
+
+// TypedLRUEvictionPolicy represents a typed least recently used eviction policy
+type TypedLRUEvictionPolicy[K comparable, V any] struct {
+	list *list.List
+}
+
+// Add adds the item to the back of the LRU list
+func (p *TypedLRUEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	i.element = p.list.PushBack(i)
+}
+
+// Remove removes the item from the LRU list
+func (p *TypedLRUEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	p.list.Remove(i.element)
+}
+
+// RecordAccess moves the item to the back of the LRU list
+func (p *TypedLRUEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	p.list.MoveToBack(i.element)
+}
+
+// Evict removes and returns the least recently used item
+func (p *TypedLRUEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items from least to most recently used
+func (p *TypedLRUEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	for el := p.list.Front(); el != nil; el = el.Next() {
+		if !fn(el.Value.(*TypedItem[K, V])) {
+			return
+		}
+	}
+}
+
+func (p *TypedLRUEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	return evictList(p.list, fn)
+}
+
+// NewFIFOEvictionPolicy returns a new FIFOEvictionPolicy
+func NewFIFOEvictionPolicy() EvictionPolicy {
+	return NewTypedFIFOEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedFIFOEvictionPolicy returns a new TypedFIFOEvictionPolicy
+func NewTypedFIFOEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedFIFOEvictionPolicy[K, V]{list: list.New()}
+}
+
+// TypedFIFOEvictionPolicy represents a typed first in, first out eviction policy. Accesses
+// are not recorded, so items are evicted in the order that they were added. This suits
+// caches where the expiration policy rather than recency determines the item lifetime,
+// and allows hits to be served concurrently under a read lock when the expiration policy
+// does not update items (NoExpirationPolicy and FixedExpirationPolicy).
+type TypedFIFOEvictionPolicy[K comparable, V any] struct {
+	list *list.List
+}
+
+// Add adds the item to the back of the list
+func (p *TypedFIFOEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	i.element = p.list.PushBack(i)
+}
+
+// Remove removes the item from the list
+func (p *TypedFIFOEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	p.list.Remove(i.element)
+}
+
+// RecordAccess is a no-op as accesses do not affect the eviction order
+func (p *TypedFIFOEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+}
+
+// Evict removes and returns the first added item
+func (p *TypedFIFOEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items from first to last added
+func (p *TypedFIFOEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	for el := p.list.Front(); el != nil; el = el.Next() {
+		if !fn(el.Value.(*TypedItem[K, V])) {
+			return
+		}
+	}
+}
+
+func (p *TypedFIFOEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	return evictList(p.list, fn)
+}
+
+// NewApproximateLRUEvictionPolicy returns a new ApproximateLRUEvictionPolicy
+func NewApproximateLRUEvictionPolicy() EvictionPolicy {
+	return NewTypedApproximateLRUEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedApproximateLRUEvictionPolicy returns a new TypedApproximateLRUEvictionPolicy
+func NewTypedApproximateLRUEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedApproximateLRUEvictionPolicy[K, V]{list: list.New()}
+}
+
+// TypedApproximateLRUEvictionPolicy represents a typed second chance approximation of
+// a least recently used eviction policy. Accesses mark the item rather than moving it
+// in the LRU list and marked items are moved to the back of the list instead of being
+// evicted. This allows hits to be served concurrently under a read lock when the
+// expiration policy does not update items (NoExpirationPolicy and FixedExpirationPolicy),
+// at the cost of evicting items that may have been accessed after more recently used items.
+type TypedApproximateLRUEvictionPolicy[K comparable, V any] struct {
+	list *list.List
+}
+
+// Add adds the item to the back of the LRU list
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	i.element = p.list.PushBack(i)
+}
+
+// Remove removes the item from the LRU list
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	p.list.Remove(i.element)
+}
+
+// RecordAccess marks the item as accessed. It is safe for concurrent use.
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	atomic.StoreInt32(&i.accessed, 1)
+}
+
+// Evict removes and returns the least recently used item that has not been accessed
+// since it was last considered for eviction
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items in LRU list order
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	for el := p.list.Front(); el != nil; el = el.Next() {
+		if !fn(el.Value.(*TypedItem[K, V])) {
+			return
+		}
+	}
+}
+
+// evictFunc leaves skipped items in place with their access mark. Each item that can be
+// evicted is moved to the back of the list at most once, so the whole list is considered
+// in at most two passes.
+func (p *TypedApproximateLRUEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	for el, n := p.list.Front(), 2*p.list.Len(); el != nil && n > 0; n-- {
+		next := el.Next()
+		if next == nil {
+			next = p.list.Front()
+		}
+
+		i := el.Value.(*TypedItem[K, V])
+		if fn(i) {
+			if atomic.SwapInt32(&i.accessed, 0) == 0 {
+				p.list.Remove(el)
+				return i
+			}
+
+			p.list.MoveToBack(el)
+		}
+
+		el = next
+	}
+
+	return nil
+}
+
+// NewLFUEvictionPolicy returns a new LFUEvictionPolicy
+func NewLFUEvictionPolicy() EvictionPolicy {
+	return NewTypedLFUEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedLFUEvictionPolicy returns a new TypedLFUEvictionPolicy
+func NewTypedLFUEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedLFUEvictionPolicy[K, V]{
+		entries: map[*TypedItem[K, V]]*lfuEntry[K, V]{},
+	}
+}
+
+// TypedLFUEvictionPolicy represents a typed least frequently used eviction policy.
+// Items with the lowest access count are evicted first, with ties broken by least
+// recent access. Access counts are not decayed.
+type TypedLFUEvictionPolicy[K comparable, V any] struct {
+	entries map[*TypedItem[K, V]]*lfuEntry[K, V]
+	heap    lfuHeap[K, V]
+	seq     uint64
+}
+
+// Add starts tracking the item with an access count of one
+func (p *TypedLFUEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	p.seq++
+
+	e := &lfuEntry[K, V]{item: i, count: 1, seq: p.seq}
+	p.entries[i] = e
+	heap.Push(&p.heap, e)
+}
+
+// Remove stops tracking the item
+func (p *TypedLFUEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	if e, ok := p.entries[i]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, i)
+	}
+}
+
+// RecordAccess increments the item access count
+func (p *TypedLFUEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	if e, ok := p.entries[i]; ok {
+		p.seq++
+
+		e.count++
+		e.seq = p.seq
+		heap.Fix(&p.heap, e.index)
+	}
+}
+
+// Evict removes and returns the least frequently used item
+func (p *TypedLFUEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items from least to most frequently used
+func (p *TypedLFUEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	es := make(lfuHeap[K, V], len(p.heap))
+	copy(es, p.heap)
+	sort.Slice(es, es.Less)
+
+	for _, e := range es {
+		if !fn(e.item) {
+			return
+		}
+	}
+}
+
+// evictFunc pushes skipped entries back onto the heap with their access count and
+// sequence, so their order is unchanged
+func (p *TypedLFUEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	var skipped []*lfuEntry[K, V]
+	defer func() {
+		for _, e := range skipped {
+			heap.Push(&p.heap, e)
+		}
+	}()
+
+	for len(p.heap) > 0 {
+		e := heap.Pop(&p.heap).(*lfuEntry[K, V])
+		if fn(e.item) {
+			delete(p.entries, e.item)
+			return e.item
+		}
+
+		skipped = append(skipped, e)
+	}
+
+	return nil
+}
+
+// twoQueueRecentRatio is the proportion of tracked items above which items are
+// evicted from the recent queue in preference to the frequent queue
+const twoQueueRecentRatio = 0.25
+
+// NewTwoQueueEvictionPolicy returns a new TwoQueueEvictionPolicy
+func NewTwoQueueEvictionPolicy() EvictionPolicy {
+	return NewTypedTwoQueueEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedTwoQueueEvictionPolicy returns a new TypedTwoQueueEvictionPolicy
+func NewTypedTwoQueueEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedTwoQueueEvictionPolicy[K, V]{
+		recent:   list.New(),
+		frequent: list.New(),
+		entries:  map[*TypedItem[K, V]]*queueEntry{},
+	}
+}
+
+// TypedTwoQueueEvictionPolicy represents a typed simplified 2Q eviction policy.
+// New items are added to a first in, first out recent queue and are promoted to a
+// least recently used frequent queue when they are accessed again. Items are evicted
+// from the recent queue while it holds at least a quarter of the tracked items, so that
+// items that are only accessed once, such as by a scan, do not displace frequently
+// accessed items.
+type TypedTwoQueueEvictionPolicy[K comparable, V any] struct {
+	recent   *list.List
+	frequent *list.List
+	entries  map[*TypedItem[K, V]]*queueEntry
+}
+
+// Add adds the item to the back of the recent queue
+func (p *TypedTwoQueueEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	p.entries[i] = &queueEntry{element: p.recent.PushBack(i)}
+}
+
+// Remove removes the item from its queue
+func (p *TypedTwoQueueEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	if e, ok := p.entries[i]; ok {
+		p.queue(e).Remove(e.element)
+		delete(p.entries, i)
+	}
+}
+
+// RecordAccess promotes the item to the frequent queue, or moves it to the back of
+// the frequent queue if it has already been promoted
+func (p *TypedTwoQueueEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	e, ok := p.entries[i]
+	if !ok {
+		return
+	}
+
+	if e.frequent {
+		p.frequent.MoveToBack(e.element)
+		return
+	}
+
+	p.recent.Remove(e.element)
+	e.element = p.frequent.PushBack(i)
+	e.frequent = true
+}
+
+// Evict removes and returns the oldest item in the recent queue if the queue exceeds
+// its share of the tracked items, otherwise the least recently used frequent item
+func (p *TypedTwoQueueEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items in the recent queue from oldest to newest, followed by
+// the items in the frequent queue from least to most recently used
+func (p *TypedTwoQueueEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	for _, q := range []*list.List{p.recent, p.frequent} {
+		for el := q.Front(); el != nil; el = el.Next() {
+			if !fn(el.Value.(*TypedItem[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+// evictFunc selects from the other queue if no item in the preferred queue can be evicted
+func (p *TypedTwoQueueEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	qs := []*list.List{p.frequent, p.recent}
+	if p.recent.Len() > 0 && (p.frequent.Len() == 0 || float64(p.recent.Len()) >= twoQueueRecentRatio*float64(len(p.entries))) {
+		qs[0], qs[1] = qs[1], qs[0]
+	}
+
+	for _, q := range qs {
+		if i := evictList(q, fn); i != nil {
+			delete(p.entries, i)
+			return i
+		}
+	}
+
+	return nil
+}
+
+func (p *TypedTwoQueueEvictionPolicy[K, V]) queue(e *queueEntry) *list.List {
+	if e.frequent {
+		return p.frequent
+	}
+
+	return p.recent
+}
+
+// NewARCEvictionPolicy returns a new ARCEvictionPolicy
+func NewARCEvictionPolicy() EvictionPolicy {
+	return NewTypedARCEvictionPolicy[string, interface{}]()
+}
+
+// NewTypedARCEvictionPolicy returns a new TypedARCEvictionPolicy
+func NewTypedARCEvictionPolicy[K comparable, V any]() TypedEvictionPolicy[K, V] {
+	return &TypedARCEvictionPolicy[K, V]{
+		recent:        list.New(),
+		frequent:      list.New(),
+		recentGhost:   newGhostList[K](),
+		frequentGhost: newGhostList[K](),
+		entries:       map[*TypedItem[K, V]]*queueEntry{},
+	}
+}
+
+// TypedARCEvictionPolicy represents a typed adaptive replacement cache eviction policy.
+// Items are tracked in recent and frequent least recently used lists, along with ghost
+// lists of the keys recently evicted from each. Adding a key that is in a ghost list
+// adapts the target size of the recent list in favour of the list it was evicted from.
+// Ghost lists are limited to the largest number of items that have been tracked.
+type TypedARCEvictionPolicy[K comparable, V any] struct {
+	recent        *list.List
+	frequent      *list.List
+	recentGhost   *ghostList[K]
+	frequentGhost *ghostList[K]
+	entries       map[*TypedItem[K, V]]*queueEntry
+	target        int
+	size          int
+}
+
+// Add adds the item to the recent list, or to the frequent list if the key was
+// recently evicted
+func (p *TypedARCEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	e := new(queueEntry)
+
+	switch {
+	case p.recentGhost.remove(i.Key):
+		p.target = min(p.target+max(1, p.frequentGhost.len()/max(1, p.recentGhost.len())), p.size)
+		e.frequent = true
+	case p.frequentGhost.remove(i.Key):
+		p.target = max(p.target-max(1, p.recentGhost.len()/max(1, p.frequentGhost.len())), 0)
+		e.frequent = true
+	}
+
+	e.element = p.queue(e).PushBack(i)
+	p.entries[i] = e
+
+	if len(p.entries) > p.size {
+		p.size = len(p.entries)
+	}
+}
+
+// Remove removes the item from its list without recording it in a ghost list
+func (p *TypedARCEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	if e, ok := p.entries[i]; ok {
+		p.queue(e).Remove(e.element)
+		delete(p.entries, i)
+	}
+}
+
+// RecordAccess moves the item to the back of the frequent list
+func (p *TypedARCEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	e, ok := p.entries[i]
+	if !ok {
+		return
+	}
+
+	if e.frequent {
+		p.frequent.MoveToBack(e.element)
+		return
+	}
+
+	p.recent.Remove(e.element)
+	e.element = p.frequent.PushBack(i)
+	e.frequent = true
+}
+
+// Evict removes and returns the least recently used item from the recent list if it
+// exceeds the target size, otherwise from the frequent list. The item key is added to
+// the corresponding ghost list.
+func (p *TypedARCEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items in the recent list followed by the items in the frequent
+// list, each from least to most recently used
+func (p *TypedARCEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	for _, q := range []*list.List{p.recent, p.frequent} {
+		for el := q.Front(); el != nil; el = el.Next() {
+			if !fn(el.Value.(*TypedItem[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+// evictFunc selects from the other list if no item in the preferred list can be evicted.
+// Only the key of the evicted item is added to a ghost list.
+func (p *TypedARCEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	qs := []*list.List{p.frequent, p.recent}
+	gs := []*ghostList[K]{p.frequentGhost, p.recentGhost}
+	if p.recent.Len() > 0 && (p.frequent.Len() == 0 || p.recent.Len() > p.target) {
+		qs[0], qs[1] = qs[1], qs[0]
+		gs[0], gs[1] = gs[1], gs[0]
+	}
+
+	for idx, q := range qs {
+		if i := evictList(q, fn); i != nil {
+			delete(p.entries, i)
+
+			gs[idx].add(i.Key, p.size)
+			return i
+		}
+	}
+
+	return nil
+}
+
+func (p *TypedARCEvictionPolicy[K, V]) queue(e *queueEntry) *list.List {
+	if e.frequent {
+		return p.frequent
+	}
+
+	return p.recent
+}
+
+// defaultEvictionSamples is the number of items sampled by SampledEvictionPolicy if
+// the specified number is not positive
+const defaultEvictionSamples = 5
+
+// NewSampledEvictionPolicy returns a new SampledEvictionPolicy that samples the specified
+// number of items for each eviction. If the number is not positive then 5 items are sampled.
+func NewSampledEvictionPolicy(samples int) EvictionPolicy {
+	return NewTypedSampledEvictionPolicy[string, interface{}](samples)
+}
+
+// NewTypedSampledEvictionPolicy returns a new TypedSampledEvictionPolicy that samples the
+// specified number of items for each eviction. If the number is not positive then 5 items
+// are sampled.
+func NewTypedSampledEvictionPolicy[K comparable, V any](samples int) TypedEvictionPolicy[K, V] {
+	if samples < 1 {
+		samples = defaultEvictionSamples
+	}
+
+	return &TypedSampledEvictionPolicy[K, V]{
+		samples: samples,
+		indexes: map[*TypedItem[K, V]]int{},
+	}
+}
+
+// TypedSampledEvictionPolicy represents a typed sampled approximation of a least recently
+// used eviction policy. Accesses record a sequence number for the item rather than moving
+// it in a list, and the least recently used of a random sample of items is evicted. Larger
+// samples are more accurate, but make eviction more expensive. If the number of tracked
+// items does not exceed the sample size then the least recently used item is evicted.
+// The random source is Options.Rand.
+type TypedSampledEvictionPolicy[K comparable, V any] struct {
+	samples int
+	entries []sampledEntry[K, V]
+	indexes map[*TypedItem[K, V]]int
+	seq     uint64
+	rand    *rand.Rand
+}
+
+// Add starts tracking the item as the most recently used
+func (p *TypedSampledEvictionPolicy[K, V]) Add(i *TypedItem[K, V]) {
+	p.seq++
+
+	p.indexes[i] = len(p.entries)
+	p.entries = append(p.entries, sampledEntry[K, V]{item: i, seq: p.seq})
+}
+
+// Remove stops tracking the item
+func (p *TypedSampledEvictionPolicy[K, V]) Remove(i *TypedItem[K, V]) {
+	if idx, ok := p.indexes[i]; ok {
+		p.remove(idx)
+	}
+}
+
+// RecordAccess records the item as the most recently used
+func (p *TypedSampledEvictionPolicy[K, V]) RecordAccess(i *TypedItem[K, V]) {
+	if idx, ok := p.indexes[i]; ok {
+		p.seq++
+		p.entries[idx].seq = p.seq
+	}
+}
+
+// Evict removes and returns the least recently used of a random sample of items
+func (p *TypedSampledEvictionPolicy[K, V]) Evict() *TypedItem[K, V] {
+	return p.evictFunc(evictAny[K, V])
+}
+
+// Range iterates the items from least to most recently used
+func (p *TypedSampledEvictionPolicy[K, V]) Range(fn func(*TypedItem[K, V]) bool) {
+	es := make([]sampledEntry[K, V], len(p.entries))
+	copy(es, p.entries)
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].seq < es[j].seq
+	})
+
+	for _, e := range es {
+		if !fn(e.item) {
+			return
+		}
+	}
+}
+
+// evictFunc considers all items if none of the sampled items can be evicted
+func (p *TypedSampledEvictionPolicy[K, V]) evictFunc(fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	evict := -1
+	consider := func(idx int) {
+		if (evict < 0 || p.entries[idx].seq < p.entries[evict].seq) && fn(p.entries[idx].item) {
+			evict = idx
+		}
+	}
+
+	n := len(p.entries)
+	if n > p.samples {
+		for s := 0; s < p.samples; s++ {
+			consider(p.intn(n))
+		}
+	}
+	if evict < 0 {
+		for idx := range p.entries {
+			consider(idx)
+		}
+	}
+	if evict < 0 {
+		return nil
+	}
+
+	i := p.entries[evict].item
+	p.remove(evict)
+
+	return i
+}
+
+func (p *TypedSampledEvictionPolicy[K, V]) useRand(r *rand.Rand) {
+	p.rand = r
+}
+
+// remove removes the entry at the specified index by replacing it with the last entry
+func (p *TypedSampledEvictionPolicy[K, V]) remove(idx int) {
+	delete(p.indexes, p.entries[idx].item)
+
+	last := len(p.entries) - 1
+	if idx != last {
+		p.entries[idx] = p.entries[last]
+		p.indexes[p.entries[idx].item] = idx
+	}
+
+	p.entries[last] = sampledEntry[K, V]{}
+	p.entries = p.entries[:last]
+}
+
+func (p *TypedSampledEvictionPolicy[K, V]) intn(n int) int {
+	if p.rand == nil {
+		return rand.Intn(n)
+	}
+
+	return p.rand.Intn(n)
+}
+
+// evictList removes and returns the first item in the list for which the func returns true
+func evictList[K comparable, V any](l *list.List, fn func(*TypedItem[K, V]) bool) *TypedItem[K, V] {
+	for el := l.Front(); el != nil; el = el.Next() {
+		if i := el.Value.(*TypedItem[K, V]); fn(i) {
+			l.Remove(el)
+			return i
+		}
+	}
+
+	return nil
+}
+
+type sampledEntry[K comparable, V any] struct {
+	item *TypedItem[K, V]
+	seq  uint64
+}
+
+type ghostList[K comparable] struct {
+	list     *list.List
+	elements map[K]*list.Element
+}
+
+func newGhostList[K comparable]() *ghostList[K] {
+	return &ghostList[K]{
+		list:     list.New(),
+		elements: map[K]*list.Element{},
+	}
+}
+
+func (g *ghostList[K]) len() int {
+	return g.list.Len()
+}
+
+// add adds the key to the back of the list, removing keys from the front of the
+// list until it does not exceed the specified size
+func (g *ghostList[K]) add(key K, size int) {
+	g.remove(key)
+	g.elements[key] = g.list.PushBack(key)
+
+	for g.list.Len() > size {
+		delete(g.elements, g.list.Remove(g.list.Front()).(K))
+	}
+}
+
+// remove removes the key and returns true if it was in the list
+func (g *ghostList[K]) remove(key K) bool {
+	el, ok := g.elements[key]
+	if !ok {
+		return false
+	}
+
+	g.list.Remove(el)
+	delete(g.elements, key)
+
+	return true
+}
+
+type queueEntry struct {
+	element  *list.Element
+	frequent bool
+}
+
+type lfuEntry[K comparable, V any] struct {
+	item  *TypedItem[K, V]
+	count uint64
+	seq   uint64
+	index int
+}
+
+type lfuHeap[K comparable, V any] []*lfuEntry[K, V]
+
+func (h lfuHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap[K, V]) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K, V]) Push(x interface{}) {
+	e := x.(*lfuEntry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return e
+}
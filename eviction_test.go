@@ -0,0 +1,349 @@
+package lru_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestEvictionPolicies(t *testing.T) {
+	tests := []struct {
+		eviction func() lru.EvictionPolicy
+		access   []string
+		evicted  []string
+		keys     []string
+	}{
+		{
+			eviction: nil,
+			access:   []string{"key_1", "key_1", "key_1", "key_2"},
+			evicted:  []string{"key_3"},
+			keys:     []string{"key_1", "key_2", "key_4"},
+		},
+		{
+			eviction: lru.NewLRUEvictionPolicy,
+			access:   []string{"key_1", "key_1", "key_1", "key_2"},
+			evicted:  []string{"key_3"},
+			keys:     []string{"key_1", "key_2", "key_4"},
+		},
+		{
+			eviction: lru.NewApproximateLRUEvictionPolicy,
+			access:   []string{"key_2"},
+			evicted:  []string{"key_1"},
+			keys:     []string{"key_2", "key_3", "key_4"},
+		},
+		{
+			eviction: lru.NewLFUEvictionPolicy,
+			access:   []string{"key_1", "key_1", "key_3", "key_2"},
+			evicted:  []string{"key_3"},
+			keys:     []string{"key_4", "key_2", "key_1"},
+		},
+		{
+			eviction: lru.NewLFUEvictionPolicy,
+			access:   []string{"key_3", "key_3", "key_1", "key_2", "key_2"},
+			evicted:  []string{"key_1"},
+			keys:     []string{"key_4", "key_3", "key_2"},
+		},
+		{
+			eviction: lru.NewTwoQueueEvictionPolicy,
+			access:   []string{"key_1"},
+			evicted:  []string{"key_2"},
+			keys:     []string{"key_3", "key_4", "key_1"},
+		},
+		{
+			eviction: lru.NewTwoQueueEvictionPolicy,
+			access:   []string{"key_1", "key_2", "key_1"},
+			evicted:  []string{"key_3"},
+			keys:     []string{"key_4", "key_2", "key_1"},
+		},
+		{
+			eviction: lru.NewARCEvictionPolicy,
+			access:   []string{"key_1"},
+			evicted:  []string{"key_2"},
+			keys:     []string{"key_3", "key_4", "key_1"},
+		},
+		{
+			eviction: lru.NewFIFOEvictionPolicy,
+			access:   []string{"key_1", "key_1", "key_2"},
+			evicted:  []string{"key_1"},
+			keys:     []string{"key_2", "key_3", "key_4"},
+		},
+		{
+			eviction: func() lru.EvictionPolicy {
+				return lru.NewSampledEvictionPolicy(0)
+			},
+			access:  []string{"key_1", "key_1", "key_3", "key_2"},
+			evicted: []string{"key_1"},
+			keys:    []string{"key_3", "key_2", "key_4"},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Eviction: tt.eviction,
+		})
+
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		for _, k := range []string{"key_1", "key_2", "key_3"} {
+			c.Set(k, k, 0)
+		}
+
+		for _, k := range tt.access {
+			req := lru.GetOrAdd{
+				Key: k,
+				Create: func() (interface{}, error) {
+					t.Errorf("Create(%d); got invocation, expected none", tn)
+					return nil, nil
+				},
+			}
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		c.Set("key_4", "key_4", 0)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("Set(%d); got %v evicted, expected %v", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+
+		c.Remove("key_4")
+		c.Clear()
+
+		if act := c.Len(); act != 0 {
+			t.Errorf("Clear(%d); got %d items, expected 0", tn, act)
+		}
+	}
+}
+
+func TestTwoQueueEvictionPolicyScan(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Capacity: 10,
+		Eviction: lru.NewTwoQueueEvictionPolicy,
+	})
+
+	hot := []string{"hot_1", "hot_2", "hot_3"}
+	for _, k := range hot {
+		c.Set(k, k, 0)
+		c.Touch(k)
+	}
+
+	for idx := 0; idx < 100; idx++ {
+		c.Set(fmt.Sprintf("scan_%d", idx), idx, 0)
+	}
+
+	for _, k := range hot {
+		if act := c.Contains(k); !act {
+			t.Errorf("Contains(%s); got %v, expected true", k, act)
+		}
+	}
+}
+
+func TestARCEvictionPolicyGhost(t *testing.T) {
+	evicted := []string{}
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 3,
+		Eviction: lru.NewARCEvictionPolicy,
+	})
+	c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+		evicted = append(evicted, i.Key)
+	}
+
+	for _, k := range []string{"key_1", "key_2", "key_3", "key_4", "key_1", "key_5"} {
+		c.Set(k, k, 0)
+	}
+
+	exp := []string{"key_1", "key_2", "key_3"}
+	if fmt.Sprint(evicted) != fmt.Sprint(exp) {
+		t.Errorf("Set(); got %v evicted, expected %v", evicted, exp)
+	}
+
+	exp = []string{"key_4", "key_5", "key_1"}
+	if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(exp) {
+		t.Errorf("Keys(); got %v, expected %v", act, exp)
+	}
+}
+
+func TestSampledEvictionPolicyWithRand(t *testing.T) {
+	run := func() []string {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 10,
+			Eviction: func() lru.EvictionPolicy {
+				return lru.NewSampledEvictionPolicy(3)
+			},
+			Rand: rand.New(rand.NewSource(1)),
+		})
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		for idx := 0; idx < 20; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+			c.Touch("key_0")
+		}
+
+		if act := c.Len(); act != 10 {
+			t.Errorf("Len(); got %d, expected 10", act)
+		}
+		if act := c.Contains("key_0"); !act {
+			t.Errorf("Contains(); got %v, expected true", act)
+		}
+
+		return evicted
+	}
+
+	exp := run()
+	if act := run(); fmt.Sprint(act) != fmt.Sprint(exp) {
+		t.Errorf("Set(); got %v evicted, expected %v", act, exp)
+	}
+}
+
+func TestEvictionPoliciesCanEvict(t *testing.T) {
+	tests := []struct {
+		eviction func() lru.EvictionPolicy
+		evicted  string
+		keys     []string
+	}{
+		{
+			eviction: lru.NewLRUEvictionPolicy,
+			evicted:  "key_2",
+			keys:     []string{"key_1", "key_0", "key_3"},
+		},
+		{
+			eviction: lru.NewApproximateLRUEvictionPolicy,
+			evicted:  "key_1",
+			keys:     []string{"key_0", "key_2", "key_3"},
+		},
+		{
+			eviction: lru.NewLFUEvictionPolicy,
+			evicted:  "key_2",
+			keys:     []string{"key_3", "key_1", "key_0"},
+		},
+		{
+			eviction: lru.NewTwoQueueEvictionPolicy,
+			evicted:  "key_2",
+			keys:     []string{"key_3", "key_1", "key_0"},
+		},
+		{
+			eviction: lru.NewARCEvictionPolicy,
+			evicted:  "key_2",
+			keys:     []string{"key_3", "key_1", "key_0"},
+		},
+		{
+			eviction: lru.NewFIFOEvictionPolicy,
+			evicted:  "key_1",
+			keys:     []string{"key_0", "key_2", "key_3"},
+		},
+		{
+			eviction: func() lru.EvictionPolicy {
+				return lru.NewSampledEvictionPolicy(3)
+			},
+			evicted: "key_2",
+			keys:    []string{"key_1", "key_0", "key_3"},
+		},
+	}
+
+	for tn, tt := range tests {
+		var evicted string
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 3,
+			Eviction: tt.eviction,
+		})
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = i.Key
+		}
+
+		for idx := 0; idx < 3; idx++ {
+			c.Set(fmt.Sprintf("key_%d", idx), idx, 0)
+		}
+		for _, k := range []string{"key_1", "key_2", "key_0"} {
+			c.Touch(k)
+		}
+
+		// the first item in eviction order is skipped without affecting its eviction state
+		skip := c.Keys()[0]
+		c.CanEvict = func(i *lru.Item) bool {
+			return i.Key != skip
+		}
+
+		c.Set("key_3", 3, 0)
+
+		if evicted != tt.evicted {
+			t.Errorf("ItemEvicted(%d); got %s, expected %s", tn, evicted, tt.evicted)
+		}
+		if act := c.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+			t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+		}
+	}
+}
+
+func TestEvictionPoliciesInvariants(t *testing.T) {
+	tests := []struct {
+		eviction func() lru.EvictionPolicy
+	}{
+		{eviction: lru.NewLRUEvictionPolicy},
+		{eviction: lru.NewApproximateLRUEvictionPolicy},
+		{eviction: lru.NewLFUEvictionPolicy},
+		{eviction: lru.NewTwoQueueEvictionPolicy},
+		{eviction: lru.NewARCEvictionPolicy},
+		{eviction: lru.NewFIFOEvictionPolicy},
+		{eviction: func() lru.EvictionPolicy {
+			return lru.NewSampledEvictionPolicy(3)
+		}},
+	}
+
+	for tn, tt := range tests {
+		r := rand.New(rand.NewSource(int64(tn)))
+
+		c := lru.NewCache(lru.Options{
+			Capacity:  8,
+			Eviction:  tt.eviction,
+			GhostSize: 4,
+		})
+
+		for idx := 0; idx < 1000; idx++ {
+			k := fmt.Sprintf("key_%d", r.Intn(16))
+
+			switch r.Intn(6) {
+			case 0:
+				c.Set(k, idx, 0)
+			case 1:
+				c.Remove(k)
+			case 2:
+				c.Take(k)
+			case 3:
+				c.UpdateValue(k, idx)
+			case 4:
+				c.GetOrAdd(&lru.GetOrAdd{
+					Key:    k,
+					Weight: 1 + r.Intn(2),
+					Tags:   []string{fmt.Sprintf("tag_%d", r.Intn(3))},
+					Create: func() (interface{}, error) {
+						return idx, nil
+					},
+				})
+			default:
+				c.InvalidateTag(fmt.Sprintf("tag_%d", r.Intn(3)))
+			}
+
+			if err := c.CheckInvariants(); err != nil {
+				t.Fatalf("CheckInvariants(%d); got %v after %d operations, expected nil", tn, err, idx+1)
+			}
+		}
+	}
+}
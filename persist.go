@@ -0,0 +1,149 @@
+package lru
+
+import (
+	"encoding/gob"
+	"io"
+	"time"
+)
+
+// Save writes the non-expired cache items to the writer using encoding/gob. Items are
+// written in eviction order with their keys, values, tags, expiry and timestamps. Value types stored in
+// an interface must be registered with gob.Register.
+func (c *TypedCache[K, V]) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(c.entries())
+}
+
+// Load reads items written by Save from the reader and adds them to the cache in order,
+// replacing any existing items with the same keys. The saved expiry and timestamps are
+// retained and items that have expired are not added, nor are empty keys if
+// RejectEmptyKeys is set. Load returns ErrClosed if the cache is closed.
+func (c *TypedCache[K, V]) Load(r io.Reader) error {
+	var es []entry[K, V]
+	if err := gob.NewDecoder(r).Decode(&es); err != nil {
+		return err
+	}
+
+	return c.load(es)
+}
+
+// Save writes the non-expired items from all shards to the writer using encoding/gob
+func (c *TypedShardedCache[K, V]) Save(w io.Writer) error {
+	var es []entry[K, V]
+	for _, s := range c.shards {
+		es = append(es, s.entries()...)
+	}
+
+	return gob.NewEncoder(w).Encode(es)
+}
+
+// Load reads items written by Save from the reader and adds them to the shards
+func (c *TypedShardedCache[K, V]) Load(r io.Reader) error {
+	var es []entry[K, V]
+	if err := gob.NewDecoder(r).Decode(&es); err != nil {
+		return err
+	}
+
+	ses := map[*TypedCache[K, V]][]entry[K, V]{}
+	for _, e := range es {
+		s := c.shard(e.Key)
+		ses[s] = append(ses[s], e)
+	}
+
+	for s, es := range ses {
+		if err := s.load(es); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entries returns the non-expired items in eviction order
+func (c *TypedCache[K, V]) entries() []entry[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	es := make([]entry[K, V], 0, len(c.items))
+	c.eviction.Range(func(i *TypedItem[K, V]) bool {
+		if c.unexpired(i) && i.err == nil {
+			es = append(es, entry[K, V]{
+				Key:        i.Key,
+				Value:      i.Value,
+				Expires:    i.Expires,
+				Created:    i.Created,
+				LastAccess: i.LastAccess,
+				Tags:       i.Tags,
+			})
+		}
+
+		return true
+	})
+
+	return es
+}
+
+// load adds the non-expired entries to the cache in order, in batches of up to
+// Options.BatchSize entries
+func (c *TypedCache[K, V]) load(es []entry[K, V]) error {
+	var err error
+	c.batched(len(es), func(start, end int) bool {
+		err = c.loadBatch(es[start:end])
+		return err == nil
+	})
+
+	return err
+}
+
+// loadBatch adds the non-expired entries to the cache in order under a single lock acquisition
+func (c *TypedCache[K, V]) loadBatch(es []entry[K, V]) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	for _, e := range es {
+		if c.rejected(e.Key) {
+			continue
+		}
+
+		i := &TypedItem[K, V]{
+			Key:        e.Key,
+			Value:      e.Value,
+			Expires:    e.Expires,
+			Created:    e.Created,
+			LastAccess: e.LastAccess,
+			Tags:       e.Tags,
+			weight:     1,
+		}
+
+		if !c.unexpired(i) {
+			continue
+		}
+
+		c.supersede(e.Key)
+
+		p, replaced := c.items[e.Key]
+		if replaced {
+			c.remove(p, EvictReplaced)
+		}
+
+		c.insert(i)
+		if !replaced {
+			c.ItemAdded(i)
+		}
+	}
+
+	return nil
+}
+
+// entry represents a persisted cache item
+type entry[K comparable, V any] struct {
+	Key        K
+	Value      V
+	Expires    time.Time
+	Created    time.Time
+	LastAccess time.Time
+	Tags       []string
+}
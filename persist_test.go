@@ -0,0 +1,101 @@
+package lru_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestCacheSaveLoad(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		policy   lru.ExpirationPolicy
+		access   time.Time
+		keys     []string
+		contains bool
+	}{
+		{
+			policy:   lru.NewNoExpirationPolicy(),
+			access:   now.Add(2 * time.Minute),
+			keys:     []string{"key_0", "key_1", "key_2"},
+			contains: true,
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			access: now.Add(30 * time.Second),
+			keys:   []string{"key_0", "key_2"},
+		},
+		{
+			policy: lru.NewFixedExpirationPolicy(),
+			access: now.Add(2 * time.Minute),
+			keys:   []string{},
+		},
+	}
+
+	for tn, tt := range tests {
+		buf := new(bytes.Buffer)
+
+		src := lru.NewCache(lru.Options{
+			Policy: tt.policy,
+		})
+
+		fixTime(now, func() {
+			src.Set("key_0", "value_0", 1*time.Minute)
+			src.Set("key_1", "value_1", 10*time.Second)
+			src.Set("key_2", "value_2", 1*time.Minute)
+
+			if err := src.Save(buf); err != nil {
+				t.Errorf("Save(%d); got %v, expected nil", tn, err)
+			}
+		})
+
+		dst := lru.NewCache(lru.Options{
+			Policy: tt.policy,
+		})
+
+		fixTime(tt.access, func() {
+			if err := dst.Load(buf); err != nil {
+				t.Errorf("Load(%d); got %v, expected nil", tn, err)
+			}
+
+			if act := dst.Keys(); fmt.Sprint(act) != fmt.Sprint(tt.keys) {
+				t.Errorf("Keys(%d); got %v, expected %v", tn, act, tt.keys)
+			}
+
+			for _, k := range tt.keys {
+				req := lru.GetOrAdd{Key: k}
+				if err := dst.GetOrAdd(&req); err != nil || req.Result != "value"+k[3:] {
+					t.Errorf("GetOrAdd(%d); got %v, %v, expected value%s", tn, req.Result, err, k[3:])
+				}
+			}
+		})
+
+		fixTime(now.Add(1*time.Minute), func() {
+			if act := dst.Contains("key_0"); act != tt.contains {
+				t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.contains)
+			}
+		})
+	}
+}
+
+func TestCacheLoadClosed(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	src := lru.NewCache(lru.Options{})
+	src.Set("key", "value", 0)
+
+	if err := src.Save(buf); err != nil {
+		t.Errorf("Save(); got %v, expected nil", err)
+	}
+
+	dst := lru.NewCache(lru.Options{})
+	dst.Close()
+
+	if err := dst.Load(buf); err != lru.ErrClosed {
+		t.Errorf("Load(); got %v, expected %v", err, lru.ErrClosed)
+	}
+}
@@ -0,0 +1,502 @@
+package lru
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type (
+	// ExpirationPolicy represents a cache item expiration policy
+	ExpirationPolicy = TypedExpirationPolicy[string, interface{}]
+
+	// NoExpirationPolicy represents a non-expiring expiration policy
+	NoExpirationPolicy = TypedNoExpirationPolicy[string, interface{}]
+
+	// FixedExpirationPolicy represents a fixed expiration policy
+	FixedExpirationPolicy = TypedFixedExpirationPolicy[string, interface{}]
+
+	// SlidingExpirationPolicy represents a sliding expiration policy
+	SlidingExpirationPolicy = TypedSlidingExpirationPolicy[string, interface{}]
+
+	// AbsoluteExpirationPolicy represents an absolute expiration policy
+	AbsoluteExpirationPolicy = TypedAbsoluteExpirationPolicy[string, interface{}]
+
+	// JitteredExpirationPolicy represents a jittered expiration policy
+	JitteredExpirationPolicy = TypedJitteredExpirationPolicy[string, interface{}]
+
+	// IdleAndAbsoluteExpirationPolicy represents an idle and maximum age expiration policy
+	IdleAndAbsoluteExpirationPolicy = TypedIdleAndAbsoluteExpirationPolicy[string, interface{}]
+
+	// TieredExpirationPolicy represents a soft and hard expiration policy
+	TieredExpirationPolicy = TypedTieredExpirationPolicy[string, interface{}]
+
+	// ProbabilisticExpirationPolicy represents a probabilistic early expiration policy
+	ProbabilisticExpirationPolicy = TypedProbabilisticExpirationPolicy[string, interface{}]
+)
+
+// ErrExpired is returned by expiration policies when an item has expired
+var ErrExpired = errors.New("item has expired")
+
+// TypedExpirationPolicy represents a typed cache item expiration policy. The built-in
+// policies treat a deadline as inclusive, so an item has expired at the exact instant
+// of its expiry as well as after it. An item with a TTL of one second that is added at
+// 12:00:00 is live until 12:00:00.999999999 and has expired at 12:00:01. Policies that
+// require an exclusive deadline can implement the comparison in ApplyAt.
+type TypedExpirationPolicy[K comparable, V any] interface {
+	Apply(*TypedItem[K, V]) error
+}
+
+// TypedClockExpirationPolicy is implemented by expiration policies that can be applied
+// at a specified time. The cache uses ApplyAt in preference to Apply so that expiry is
+// evaluated using the cache clock.
+type TypedClockExpirationPolicy[K comparable, V any] interface {
+	ApplyAt(i *TypedItem[K, V], now time.Time) error
+}
+
+// TypedItemInitializer is implemented by expiration policies that initialise items
+// when they are added or replaced. Init is invoked after the item expiry has been set
+// from the request TTL.
+type TypedItemInitializer[K comparable, V any] interface {
+	Init(*TypedItem[K, V])
+}
+
+// TypedStaleExpirationPolicy is implemented by expiration policies that distinguish stale
+// items from expired items. Stale items are returned by GetOrAdd, which replaces them by
+// invoking the request create func in the background, as with RefreshThreshold.
+type TypedStaleExpirationPolicy[K comparable, V any] interface {
+	Stale(i *TypedItem[K, V], now time.Time) bool
+}
+
+// randomItemInitializer is implemented by expiration policies that initialise items
+// using the cache random source. The source is nil if the package source is used.
+type randomItemInitializer[K comparable, V any] interface {
+	initRand(*TypedItem[K, V], *rand.Rand)
+}
+
+// NewNoExpirationPolicy returns a new NoExpirationPolicy
+func NewNoExpirationPolicy() *NoExpirationPolicy {
+	return NewTypedNoExpirationPolicy[string, interface{}]()
+}
+
+// NewTypedNoExpirationPolicy returns a new TypedNoExpirationPolicy
+func NewTypedNoExpirationPolicy[K comparable, V any]() *TypedNoExpirationPolicy[K, V] {
+	return new(TypedNoExpirationPolicy[K, V])
+}
+
+// TypedNoExpirationPolicy represents a typed non-expiring expiration policy
+type TypedNoExpirationPolicy[K comparable, V any] struct {
+}
+
+// Apply is a no-op as the policy does not allow items to expire
+func (p *TypedNoExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return nil
+}
+
+// ApplyAt is a no-op as the policy does not allow items to expire
+func (p *TypedNoExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	return nil
+}
+
+// NewFixedExpirationPolicy returns a new FixedExpirationPolicy
+func NewFixedExpirationPolicy() *FixedExpirationPolicy {
+	return NewTypedFixedExpirationPolicy[string, interface{}]()
+}
+
+// NewTypedFixedExpirationPolicy returns a new TypedFixedExpirationPolicy
+func NewTypedFixedExpirationPolicy[K comparable, V any]() *TypedFixedExpirationPolicy[K, V] {
+	return new(TypedFixedExpirationPolicy[K, V])
+}
+
+// TypedFixedExpirationPolicy represents a typed fixed expiration policy
+type TypedFixedExpirationPolicy[K comparable, V any] struct {
+}
+
+// Apply returns an error if the item has expired. The item expiry will not be updated.
+// Items with a zero expiry do not expire.
+func (p *TypedFixedExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt returns an error if the item has expired at the specified time
+func (p *TypedFixedExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if i.Expires.IsZero() {
+		return nil
+	}
+
+	if expired(i.Expires, now) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// NewSlidingExpirationPolicy returns a new SlidingExpirationPolicy with
+// the specified TTL
+func NewSlidingExpirationPolicy(ttl time.Duration) *SlidingExpirationPolicy {
+	return NewTypedSlidingExpirationPolicy[string, interface{}](ttl)
+}
+
+// NewTypedSlidingExpirationPolicy returns a new TypedSlidingExpirationPolicy
+// with the specified TTL
+func NewTypedSlidingExpirationPolicy[K comparable, V any](ttl time.Duration) *TypedSlidingExpirationPolicy[K, V] {
+	return &TypedSlidingExpirationPolicy[K, V]{ttl: ttl}
+}
+
+// TypedSlidingExpirationPolicy represents a typed sliding expiration policy
+type TypedSlidingExpirationPolicy[K comparable, V any] struct {
+	ttl time.Duration
+}
+
+// Apply resets the TTL for the specified item. An error will be returned if
+// the item has expired and cannot be refreshed. Items with a zero expiry do not
+// expire and are not updated.
+func (p *TypedSlidingExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt resets the TTL for the specified item relative to the specified time
+func (p *TypedSlidingExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if i.Expires.IsZero() {
+		return nil
+	}
+
+	if expired(i.Expires, now) {
+		return ErrExpired
+	}
+
+	i.Expires = now.Add(p.ttl)
+	return nil
+}
+
+// NewAbsoluteExpirationPolicy returns a new AbsoluteExpirationPolicy that expires
+// all items at the specified time
+func NewAbsoluteExpirationPolicy(at time.Time) *AbsoluteExpirationPolicy {
+	return NewTypedAbsoluteExpirationPolicy[string, interface{}](at)
+}
+
+// NewTypedAbsoluteExpirationPolicy returns a new TypedAbsoluteExpirationPolicy that
+// expires all items at the specified time
+func NewTypedAbsoluteExpirationPolicy[K comparable, V any](at time.Time) *TypedAbsoluteExpirationPolicy[K, V] {
+	return &TypedAbsoluteExpirationPolicy[K, V]{at: at.UTC()}
+}
+
+// TypedAbsoluteExpirationPolicy represents a typed absolute expiration policy
+type TypedAbsoluteExpirationPolicy[K comparable, V any] struct {
+	at time.Time
+}
+
+// Init sets the item expiry to the configured expiry time
+func (p *TypedAbsoluteExpirationPolicy[K, V]) Init(i *TypedItem[K, V]) {
+	i.Expires = p.at
+}
+
+// Apply returns an error if the configured expiry time has passed. The request TTL
+// is ignored and the item expiry is set to the configured expiry time.
+func (p *TypedAbsoluteExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt returns an error if the configured expiry time has passed at the specified time
+func (p *TypedAbsoluteExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	i.Expires = p.at
+
+	if expired(p.at, now) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// NewJitteredExpirationPolicy returns a new JitteredExpirationPolicy that wraps the
+// specified policy and offsets each item expiry by a random duration in [0, maxJitter)
+func NewJitteredExpirationPolicy(inner ExpirationPolicy, maxJitter time.Duration) *JitteredExpirationPolicy {
+	return NewTypedJitteredExpirationPolicy(inner, maxJitter)
+}
+
+// NewTypedJitteredExpirationPolicy returns a new TypedJitteredExpirationPolicy that wraps
+// the specified policy and offsets each item expiry by a random duration in [0, maxJitter).
+// The jitter is generated using Options.Rand.
+func NewTypedJitteredExpirationPolicy[K comparable, V any](inner TypedExpirationPolicy[K, V], maxJitter time.Duration) *TypedJitteredExpirationPolicy[K, V] {
+	return &TypedJitteredExpirationPolicy[K, V]{
+		inner:     inner,
+		maxJitter: maxJitter,
+		mu:        &sync.Mutex{},
+	}
+}
+
+// NewJitteredExpirationPolicyWithSource returns a new JitteredExpirationPolicy that uses
+// the specified random source in place of Options.Rand
+func NewJitteredExpirationPolicyWithSource(inner ExpirationPolicy, maxJitter time.Duration, src rand.Source) *JitteredExpirationPolicy {
+	return NewTypedJitteredExpirationPolicyWithSource(inner, maxJitter, src)
+}
+
+// NewTypedJitteredExpirationPolicyWithSource returns a new TypedJitteredExpirationPolicy
+// that uses the specified random source in place of Options.Rand
+func NewTypedJitteredExpirationPolicyWithSource[K comparable, V any](inner TypedExpirationPolicy[K, V], maxJitter time.Duration, src rand.Source) *TypedJitteredExpirationPolicy[K, V] {
+	return &TypedJitteredExpirationPolicy[K, V]{
+		inner:     inner,
+		maxJitter: maxJitter,
+		rand:      rand.New(src),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// TypedJitteredExpirationPolicy represents a typed jittered expiration policy
+type TypedJitteredExpirationPolicy[K comparable, V any] struct {
+	inner     TypedExpirationPolicy[K, V]
+	maxJitter time.Duration
+	rand      *rand.Rand
+	mu        *sync.Mutex
+}
+
+// Init initialises the item using the wrapped policy and offsets the item expiry
+// by a random jitter. Jitter is only applied when the item is added or replaced.
+func (p *TypedJitteredExpirationPolicy[K, V]) Init(i *TypedItem[K, V]) {
+	p.initRand(i, nil)
+}
+
+// initRand initialises the item using the policy random source if set, otherwise
+// the specified source is used
+func (p *TypedJitteredExpirationPolicy[K, V]) initRand(i *TypedItem[K, V], r *rand.Rand) {
+	if ip, ok := p.inner.(TypedItemInitializer[K, V]); ok {
+		ip.Init(i)
+	}
+
+	if p.maxJitter <= 0 || i.Expires.IsZero() {
+		return
+	}
+
+	p.mu.Lock()
+	if p.rand != nil {
+		r = p.rand
+	}
+	j := time.Duration(int63n(r, int64(p.maxJitter)))
+	p.mu.Unlock()
+
+	i.Expires = i.Expires.Add(j)
+}
+
+// int63n returns a random number in [0, n) from the specified source, or from the
+// package source if nil
+func int63n(r *rand.Rand, n int64) int64 {
+	if r == nil {
+		return rand.Int63n(n)
+	}
+
+	return r.Int63n(n)
+}
+
+// Apply applies the wrapped policy to the item
+func (p *TypedJitteredExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.inner.Apply(i)
+}
+
+// ApplyAt applies the wrapped policy to the item at the specified time. If the
+// wrapped policy does not support ApplyAt then Apply is used.
+func (p *TypedJitteredExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if ip, ok := p.inner.(TypedClockExpirationPolicy[K, V]); ok {
+		return ip.ApplyAt(i, now)
+	}
+
+	return p.inner.Apply(i)
+}
+
+// NewIdleAndAbsoluteExpirationPolicy returns a new IdleAndAbsoluteExpirationPolicy that
+// expires items that have not been accessed for the idle duration or were created more
+// than the maximum age ago, whichever is earlier
+func NewIdleAndAbsoluteExpirationPolicy(idle, maxAge time.Duration) *IdleAndAbsoluteExpirationPolicy {
+	return NewTypedIdleAndAbsoluteExpirationPolicy[string, interface{}](idle, maxAge)
+}
+
+// NewTypedIdleAndAbsoluteExpirationPolicy returns a new TypedIdleAndAbsoluteExpirationPolicy
+// that expires items that have not been accessed for the idle duration or were created more
+// than the maximum age ago, whichever is earlier
+func NewTypedIdleAndAbsoluteExpirationPolicy[K comparable, V any](idle, maxAge time.Duration) *TypedIdleAndAbsoluteExpirationPolicy[K, V] {
+	return &TypedIdleAndAbsoluteExpirationPolicy[K, V]{idle: idle, maxAge: maxAge}
+}
+
+// TypedIdleAndAbsoluteExpirationPolicy represents a typed idle and maximum age expiration
+// policy. The request TTL is ignored and a zero idle duration or maximum age is not
+// enforced. The item expiry is set to the earlier of the two bounds.
+type TypedIdleAndAbsoluteExpirationPolicy[K comparable, V any] struct {
+	idle   time.Duration
+	maxAge time.Duration
+}
+
+// Init sets the item expiry from the item creation time
+func (p *TypedIdleAndAbsoluteExpirationPolicy[K, V]) Init(i *TypedItem[K, V]) {
+	i.Expires = p.expires(i)
+}
+
+// Apply returns an error if the item has expired, otherwise records the access
+func (p *TypedIdleAndAbsoluteExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt returns an error if the item has expired at the specified time, otherwise
+// records the access and updates the item expiry
+func (p *TypedIdleAndAbsoluteExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if p.idle > 0 && expired(i.LastAccess.Add(p.idle), now) {
+		return ErrExpired
+	}
+	if p.maxAge > 0 && expired(i.Created.Add(p.maxAge), now) {
+		return ErrExpired
+	}
+
+	i.LastAccess = now
+	i.Expires = p.expires(i)
+	return nil
+}
+
+// expires returns the earlier of the idle and maximum age bounds, or the zero time
+// if neither is enforced
+func (p *TypedIdleAndAbsoluteExpirationPolicy[K, V]) expires(i *TypedItem[K, V]) time.Time {
+	var exp time.Time
+	if p.idle > 0 {
+		exp = i.LastAccess.Add(p.idle)
+	}
+	if p.maxAge > 0 {
+		if ma := i.Created.Add(p.maxAge); exp.IsZero() || ma.Before(exp) {
+			exp = ma
+		}
+	}
+
+	return exp
+}
+
+// NewTieredExpirationPolicy returns a new TieredExpirationPolicy that marks items as stale
+// once the soft duration has elapsed since they were created and expires them once the hard
+// duration has elapsed
+func NewTieredExpirationPolicy(soft, hard time.Duration) *TieredExpirationPolicy {
+	return NewTypedTieredExpirationPolicy[string, interface{}](soft, hard)
+}
+
+// NewTypedTieredExpirationPolicy returns a new TypedTieredExpirationPolicy that marks items
+// as stale once the soft duration has elapsed since they were created and expires them once
+// the hard duration has elapsed
+func NewTypedTieredExpirationPolicy[K comparable, V any](soft, hard time.Duration) *TypedTieredExpirationPolicy[K, V] {
+	return &TypedTieredExpirationPolicy[K, V]{soft: soft, hard: hard}
+}
+
+// TypedTieredExpirationPolicy represents a typed soft and hard expiration policy. Stale items
+// continue to be served while they are refreshed in the background, which allows values to
+// be revalidated without blocking requests. The request TTL is ignored. If either duration
+// is zero then the corresponding deadline is not enforced.
+type TypedTieredExpirationPolicy[K comparable, V any] struct {
+	soft time.Duration
+	hard time.Duration
+}
+
+// Init sets the item expiry to the hard deadline
+func (p *TypedTieredExpirationPolicy[K, V]) Init(i *TypedItem[K, V]) {
+	i.Expires = time.Time{}
+	if p.hard > 0 {
+		i.Expires = i.Created.Add(p.hard)
+	}
+}
+
+// Apply returns an error if the item has passed the hard deadline
+func (p *TypedTieredExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt returns an error if the item has passed the hard deadline at the specified time
+func (p *TypedTieredExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if !i.Expires.IsZero() && expired(i.Expires, now) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// Stale returns true if the item has passed the soft deadline at the specified time
+func (p *TypedTieredExpirationPolicy[K, V]) Stale(i *TypedItem[K, V], now time.Time) bool {
+	return p.soft > 0 && expired(i.Created.Add(p.soft), now)
+}
+
+// NewProbabilisticExpirationPolicy returns a new ProbabilisticExpirationPolicy with the
+// specified beta
+func NewProbabilisticExpirationPolicy(beta float64) *ProbabilisticExpirationPolicy {
+	return NewTypedProbabilisticExpirationPolicy[string, interface{}](beta)
+}
+
+// NewTypedProbabilisticExpirationPolicy returns a new TypedProbabilisticExpirationPolicy
+// with the specified beta. If beta is not positive then a beta of one is used.
+func NewTypedProbabilisticExpirationPolicy[K comparable, V any](beta float64) *TypedProbabilisticExpirationPolicy[K, V] {
+	if beta <= 0 {
+		beta = 1
+	}
+
+	return &TypedProbabilisticExpirationPolicy[K, V]{beta: beta, mu: &sync.Mutex{}}
+}
+
+// NewProbabilisticExpirationPolicyWithSource returns a new ProbabilisticExpirationPolicy
+// that uses the specified random source in place of the package source
+func NewProbabilisticExpirationPolicyWithSource(beta float64, src rand.Source) *ProbabilisticExpirationPolicy {
+	return NewTypedProbabilisticExpirationPolicyWithSource[string, interface{}](beta, src)
+}
+
+// NewTypedProbabilisticExpirationPolicyWithSource returns a new TypedProbabilisticExpirationPolicy
+// that uses the specified random source in place of the package source
+func NewTypedProbabilisticExpirationPolicyWithSource[K comparable, V any](beta float64, src rand.Source) *TypedProbabilisticExpirationPolicy[K, V] {
+	p := NewTypedProbabilisticExpirationPolicy[K, V](beta)
+	p.rand = rand.New(src)
+
+	return p
+}
+
+// TypedProbabilisticExpirationPolicy represents a typed probabilistic early expiration
+// policy, which implements the XFetch algorithm. Items expire at their expiry, as with
+// FixedExpirationPolicy, but each access before then finds the item stale with a
+// probability that increases as the expiry approaches, which triggers a background
+// refresh as with RefreshThreshold. An item is stale if
+// now - CreateDuration * beta * ln(rand()) >= Expires, so items that take longer
+// to create are refreshed earlier and a beta greater than one favours earlier refreshes.
+// Items without a create duration, such as those added by Set, are never stale.
+type TypedProbabilisticExpirationPolicy[K comparable, V any] struct {
+	beta float64
+	rand *rand.Rand
+	mu   *sync.Mutex
+}
+
+// Apply returns an error if the item has expired. The item expiry will not be updated.
+func (p *TypedProbabilisticExpirationPolicy[K, V]) Apply(i *TypedItem[K, V]) error {
+	return p.ApplyAt(i, UTCNow())
+}
+
+// ApplyAt returns an error if the item has expired at the specified time
+func (p *TypedProbabilisticExpirationPolicy[K, V]) ApplyAt(i *TypedItem[K, V], now time.Time) error {
+	if !i.Expires.IsZero() && expired(i.Expires, now) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// Stale returns true if the item should be refreshed early at the specified time
+func (p *TypedProbabilisticExpirationPolicy[K, V]) Stale(i *TypedItem[K, V], now time.Time) bool {
+	if i.Expires.IsZero() || i.CreateDuration <= 0 {
+		return false
+	}
+
+	// the random number is in (0, 1] so that the logarithm is finite
+	var r float64
+	p.mu.Lock()
+	if p.rand != nil {
+		r = 1 - p.rand.Float64()
+	} else {
+		r = 1 - rand.Float64()
+	}
+	p.mu.Unlock()
+
+	early := time.Duration(-float64(i.CreateDuration) * p.beta * math.Log(r))
+	return expired(i.Expires, now.Add(early))
+}
+
+// expired returns true if the deadline is at or before the specified time
+func expired(deadline, now time.Time) bool {
+	return !now.Before(deadline)
+}
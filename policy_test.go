@@ -0,0 +1,667 @@
+package lru_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestNoExpirationPolicy(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		expire time.Time
+		access time.Time
+		err    bool
+		exp    time.Time
+	}{
+		{
+			expire: now,
+			access: now.Add(1 * time.Minute),
+			err:    false,
+			exp:    now,
+		},
+		{
+			expire: now.Add(1 * time.Minute),
+			access: now,
+			err:    false,
+			exp:    now.Add(1 * time.Minute),
+		},
+	}
+
+	for tn, tt := range tests {
+		fixTime(tt.access, func() {
+			i := lru.Item{Expires: tt.expire}
+			p := lru.NewNoExpirationPolicy()
+
+			err := p.Apply(&i)
+
+			if err != nil && !tt.err {
+				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+			}
+			if err == nil && tt.err {
+				t.Errorf("Apply(%d); got nil, expected an error", tn)
+			}
+			if err != nil && !errors.Is(err, lru.ErrExpired) {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, err, lru.ErrExpired)
+			}
+			if i.Expires != tt.expire {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.expire)
+			}
+		})
+	}
+}
+
+func TestFixedExpirationPolicy(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		expire time.Time
+		access time.Time
+		err    bool
+		exp    time.Time
+	}{
+		{
+			expire: now,
+			access: now.Add(1 * time.Minute),
+			err:    true,
+			exp:    now,
+		},
+		{
+			expire: now,
+			access: now,
+			err:    true,
+			exp:    now,
+		},
+		{
+			expire: now.Add(1 * time.Minute),
+			access: now,
+			err:    false,
+			exp:    now.Add(1 * time.Minute),
+		},
+		{
+			expire: time.Time{},
+			access: now,
+			err:    false,
+			exp:    time.Time{},
+		},
+	}
+
+	for tn, tt := range tests {
+		fixTime(tt.access, func() {
+			i := lru.Item{Expires: tt.expire}
+			p := lru.NewFixedExpirationPolicy()
+
+			err := p.Apply(&i)
+
+			if err != nil && !tt.err {
+				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+			}
+			if err == nil && tt.err {
+				t.Errorf("Apply(%d); got nil, expected an error", tn)
+			}
+			if err != nil && !errors.Is(err, lru.ErrExpired) {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, err, lru.ErrExpired)
+			}
+			if i.Expires != tt.expire {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.expire)
+			}
+		})
+	}
+}
+
+func TestSlidingExpirationPolicy(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		ttl    time.Duration
+		expire time.Time
+		access time.Time
+		err    bool
+		exp    time.Time
+	}{
+		{
+			ttl:    2 * time.Minute,
+			expire: now,
+			access: now.Add(1 * time.Minute),
+			err:    true,
+			exp:    now,
+		},
+		{
+			ttl:    2 * time.Minute,
+			expire: now,
+			access: now,
+			err:    true,
+			exp:    now,
+		},
+		{
+			ttl:    2 * time.Minute,
+			expire: now.Add(1 * time.Minute),
+			access: now,
+			err:    false,
+			exp:    now.Add(2 * time.Minute),
+		},
+		{
+			ttl:    2 * time.Minute,
+			expire: time.Time{},
+			access: now,
+			err:    false,
+			exp:    time.Time{},
+		},
+	}
+
+	for tn, tt := range tests {
+		fixTime(tt.access, func() {
+			i := lru.Item{Expires: tt.expire}
+			p := lru.NewSlidingExpirationPolicy(tt.ttl)
+
+			err := p.Apply(&i)
+
+			if err != nil && !tt.err {
+				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+			}
+			if err == nil && tt.err {
+				t.Errorf("Apply(%d); got nil, expected an error", tn)
+			}
+			if err != nil && !errors.Is(err, lru.ErrExpired) {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, err, lru.ErrExpired)
+			}
+			if i.Expires != tt.exp {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.exp)
+			}
+		})
+	}
+}
+
+func TestAbsoluteExpirationPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		at     time.Time
+		expire time.Time
+		access time.Time
+		err    bool
+	}{
+		{
+			at:     now,
+			expire: now.Add(1 * time.Minute),
+			access: now.Add(1 * time.Minute),
+			err:    true,
+		},
+		{
+			at:     now,
+			expire: now.Add(1 * time.Minute),
+			access: now,
+			err:    true,
+		},
+		{
+			at:     now.Add(1 * time.Minute),
+			expire: now,
+			access: now.Add(30 * time.Second),
+			err:    false,
+		},
+	}
+
+	for tn, tt := range tests {
+		fixTime(tt.access, func() {
+			i := lru.Item{Expires: tt.expire}
+			p := lru.NewAbsoluteExpirationPolicy(tt.at)
+
+			err := p.Apply(&i)
+
+			if err != nil && !tt.err {
+				t.Errorf("Apply(%d); got %v, expected nil", tn, err)
+			}
+			if err == nil && tt.err {
+				t.Errorf("Apply(%d); got nil, expected an error", tn)
+			}
+			if err != nil && !errors.Is(err, lru.ErrExpired) {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, err, lru.ErrExpired)
+			}
+			if !i.Expires.Equal(tt.at) {
+				t.Errorf("Apply(%d); got %v, expected %v", tn, i.Expires, tt.at)
+			}
+		})
+	}
+}
+
+func TestJitteredExpirationPolicy(t *testing.T) {
+	now := time.Now().UTC()
+	maxJitter := 10 * time.Second
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewJitteredExpirationPolicyWithSource(
+			lru.NewFixedExpirationPolicy(), maxJitter, rand.NewSource(1)),
+	})
+
+	exp := rand.New(rand.NewSource(1))
+
+	fixTime(now, func() {
+		for idx := 0; idx < 10; idx++ {
+			key := fmt.Sprintf("key:%d", idx)
+			req := lru.GetOrAdd{
+				Key: key,
+				TTL: 1 * time.Minute,
+				Create: func() (interface{}, error) {
+					return idx, nil
+				},
+			}
+
+			var expires time.Time
+			c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+				expires = i.Expires
+			}
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", idx, err)
+			}
+
+			// access must not apply further jitter
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", idx, err)
+			}
+
+			c.Remove(key)
+
+			e := now.Add(1*time.Minute + time.Duration(exp.Int63n(int64(maxJitter))))
+			if !expires.Equal(e) {
+				t.Errorf("GetOrAdd(%d); got %v, expected %v", idx, expires, e)
+			}
+		}
+	})
+}
+
+func TestJitteredExpirationPolicyWithRand(t *testing.T) {
+	now := time.Now().UTC()
+	maxJitter := 10 * time.Second
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewJitteredExpirationPolicy(lru.NewFixedExpirationPolicy(), maxJitter),
+		Rand:   rand.New(rand.NewSource(1)),
+	})
+
+	exp := rand.New(rand.NewSource(1))
+
+	var expires time.Time
+	c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+		expires = i.Expires
+	}
+
+	fixTime(now, func() {
+		for idx := 0; idx < 10; idx++ {
+			key := fmt.Sprintf("key:%d", idx)
+
+			c.Set(key, idx, 1*time.Minute)
+			c.Remove(key)
+
+			e := now.Add(1*time.Minute + time.Duration(exp.Int63n(int64(maxJitter))))
+			if !expires.Equal(e) {
+				t.Errorf("Set(%d); got %v, expected %v", idx, expires, e)
+			}
+		}
+	})
+}
+
+func TestIdleAndAbsoluteExpirationPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		idle   time.Duration
+		maxAge time.Duration
+		access []time.Time
+		exp    bool
+	}{
+		{
+			idle:   1 * time.Minute,
+			maxAge: 5 * time.Minute,
+			access: []time.Time{now.Add(30 * time.Second)},
+			exp:    true,
+		},
+		{
+			idle:   1 * time.Minute,
+			maxAge: 5 * time.Minute,
+			access: []time.Time{now.Add(1 * time.Minute)},
+			exp:    false,
+		},
+		{
+			idle:   1 * time.Minute,
+			maxAge: 5 * time.Minute,
+			access: []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second)},
+			exp:    true,
+		},
+		{
+			idle:   1 * time.Minute,
+			maxAge: 2 * time.Minute,
+			access: []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second), now.Add(2 * time.Minute)},
+			exp:    false,
+		},
+		{
+			idle:   0,
+			maxAge: 2 * time.Minute,
+			access: []time.Time{now.Add(90 * time.Second)},
+			exp:    true,
+		},
+		{
+			idle:   1 * time.Minute,
+			maxAge: 0,
+			access: []time.Time{now.Add(45 * time.Second), now.Add(90 * time.Second), now.Add(135 * time.Second)},
+			exp:    true,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Policy: lru.NewIdleAndAbsoluteExpirationPolicy(tt.idle, tt.maxAge),
+		})
+
+		fixTime(now, func() {
+			c.Set("key", "value", 0)
+		})
+
+		for idx, a := range tt.access {
+			fixTime(a, func() {
+				if idx < len(tt.access)-1 {
+					c.Touch("key")
+					return
+				}
+
+				if act := c.Contains("key"); act != tt.exp {
+					t.Errorf("Contains(%d); got %v, expected %v", tn, act, tt.exp)
+				}
+			})
+		}
+	}
+}
+
+func TestTieredExpirationPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		soft   time.Duration
+		hard   time.Duration
+		access time.Duration
+		stale  bool
+		err    bool
+	}{
+		{
+			soft:   1 * time.Minute,
+			hard:   2 * time.Minute,
+			access: 30 * time.Second,
+			stale:  false,
+			err:    false,
+		},
+		{
+			soft:   1 * time.Minute,
+			hard:   2 * time.Minute,
+			access: 1 * time.Minute,
+			stale:  true,
+			err:    false,
+		},
+		{
+			soft:   1 * time.Minute,
+			hard:   2 * time.Minute,
+			access: 2 * time.Minute,
+			stale:  true,
+			err:    true,
+		},
+		{
+			soft:   0,
+			hard:   0,
+			access: 2 * time.Minute,
+			stale:  false,
+			err:    false,
+		},
+	}
+
+	for tn, tt := range tests {
+		i := lru.Item{Created: now}
+		p := lru.NewTieredExpirationPolicy(tt.soft, tt.hard)
+		p.Init(&i)
+
+		err := p.ApplyAt(&i, now.Add(tt.access))
+
+		if err != nil && !tt.err {
+			t.Errorf("ApplyAt(%d); got %v, expected nil", tn, err)
+		}
+		if err == nil && tt.err {
+			t.Errorf("ApplyAt(%d); got nil, expected an error", tn)
+		}
+		if act := p.Stale(&i, now.Add(tt.access)); act != tt.stale {
+			t.Errorf("Stale(%d); got %v, expected %v", tn, act, tt.stale)
+		}
+	}
+}
+
+func TestTieredExpirationPolicyRefresh(t *testing.T) {
+	now := time.Now().UTC()
+
+	var invocations atomic.Int32
+	var clk atomic.Int64
+	clk.Store(now.UnixNano())
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewTieredExpirationPolicy(1*time.Minute, 2*time.Minute),
+		Clock: lru.ClockFunc(func() time.Time {
+			return time.Unix(0, clk.Load()).UTC()
+		}),
+	})
+
+	get := func() interface{} {
+		v, err := c.GetOrAddFunc("key", 0, func() (interface{}, error) {
+			return invocations.Add(1), nil
+		})
+		if err != nil {
+			t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+		}
+
+		return v
+	}
+
+	get()
+
+	// stale items are served while they are refreshed
+	clk.Add(int64(90 * time.Second))
+	if act := get(); act != int32(1) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 1", act)
+	}
+
+	var act interface{}
+	for r := 0; r < 100 && act != int32(2); r++ {
+		time.Sleep(1 * time.Millisecond)
+		act = get()
+	}
+	if act != int32(2) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 2", act)
+	}
+
+	// expired items are replaced
+	clk.Add(int64(2 * time.Minute))
+	if act := get(); act != int32(3) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 3", act)
+	}
+}
+
+func TestExpirationPolicyBoundary(t *testing.T) {
+	now := time.Now().UTC()
+	deadline := now.Add(1 * time.Second)
+
+	tests := []struct {
+		policy lru.ExpirationPolicy
+	}{
+		{policy: lru.NewFixedExpirationPolicy()},
+		{policy: lru.NewSlidingExpirationPolicy(1 * time.Second)},
+		{policy: lru.NewAbsoluteExpirationPolicy(deadline)},
+		{policy: lru.NewIdleAndAbsoluteExpirationPolicy(1*time.Second, 0)},
+		{policy: lru.NewIdleAndAbsoluteExpirationPolicy(0, 1*time.Second)},
+		{policy: lru.NewTieredExpirationPolicy(0, 1*time.Second)},
+		{policy: lru.NewProbabilisticExpirationPolicy(1)},
+	}
+
+	for tn, tt := range tests {
+		p := tt.policy.(lru.TypedClockExpirationPolicy[string, interface{}])
+
+		for _, at := range []struct {
+			now time.Time
+			err error
+		}{
+			{now: deadline.Add(-1 * time.Nanosecond), err: nil},
+			{now: deadline, err: lru.ErrExpired},
+			{now: deadline.Add(1 * time.Nanosecond), err: lru.ErrExpired},
+		} {
+			i := lru.Item{Expires: deadline, Created: now, LastAccess: now}
+
+			if err := p.ApplyAt(&i, at.now); err != at.err {
+				t.Errorf("ApplyAt(%d); got %v at %v, expected %v", tn, err, at.now.Sub(deadline), at.err)
+			}
+		}
+	}
+}
+
+func TestProbabilisticExpirationPolicy(t *testing.T) {
+	now := time.Now().UTC()
+
+	tests := []struct {
+		beta     float64
+		expires  time.Time
+		duration time.Duration
+		access   time.Time
+		err      error
+		stale    bool
+	}{
+		{
+			beta:     1,
+			expires:  now.Add(1 * time.Second),
+			duration: 1 * time.Second,
+			access:   now,
+			err:      nil,
+			stale:    false,
+		},
+		{
+			beta:     1,
+			expires:  now.Add(1 * time.Second),
+			duration: 1 * time.Second,
+			access:   now.Add(400 * time.Millisecond),
+			err:      nil,
+			stale:    true,
+		},
+		{
+			beta:     2,
+			expires:  now.Add(1 * time.Second),
+			duration: 1 * time.Second,
+			access:   now,
+			err:      nil,
+			stale:    true,
+		},
+		{
+			beta:     0,
+			expires:  now.Add(1 * time.Second),
+			duration: 1 * time.Second,
+			access:   now,
+			err:      nil,
+			stale:    false,
+		},
+		{
+			beta:     1,
+			expires:  now.Add(1 * time.Second),
+			duration: 0,
+			access:   now.Add(999 * time.Millisecond),
+			err:      nil,
+			stale:    false,
+		},
+		{
+			beta:     1,
+			expires:  time.Time{},
+			duration: 1 * time.Second,
+			access:   now,
+			err:      nil,
+			stale:    false,
+		},
+		{
+			beta:     1,
+			expires:  now.Add(1 * time.Second),
+			duration: 1 * time.Second,
+			access:   now.Add(1 * time.Second),
+			err:      lru.ErrExpired,
+			stale:    true,
+		},
+	}
+
+	for tn, tt := range tests {
+		// the source returns 0.5 from Float64, which offsets the access by 0.69 * duration * beta
+		p := lru.NewProbabilisticExpirationPolicyWithSource(tt.beta, fixedSource(1<<62))
+		i := lru.Item{Expires: tt.expires, CreateDuration: tt.duration}
+
+		if err := p.ApplyAt(&i, tt.access); err != tt.err {
+			t.Errorf("ApplyAt(%d); got %v, expected %v", tn, err, tt.err)
+		}
+		if act := p.Stale(&i, tt.access); act != tt.stale {
+			t.Errorf("Stale(%d); got %v, expected %v", tn, act, tt.stale)
+		}
+		if !i.Expires.Equal(tt.expires) {
+			t.Errorf("ApplyAt(%d); got %v, expected %v", tn, i.Expires, tt.expires)
+		}
+	}
+}
+
+func TestProbabilisticExpirationPolicyRefresh(t *testing.T) {
+	now := time.Now().UTC()
+
+	var invocations atomic.Int32
+	var clk atomic.Int64
+	clk.Store(now.UnixNano())
+
+	c := lru.NewCache(lru.Options{
+		Policy: lru.NewProbabilisticExpirationPolicyWithSource(1, fixedSource(1<<62)),
+		Clock: lru.ClockFunc(func() time.Time {
+			return time.Unix(0, clk.Load()).UTC()
+		}),
+	})
+
+	get := func() interface{} {
+		v, err := c.GetOrAddFunc("key", 1*time.Second, func() (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return invocations.Add(1), nil
+		})
+		if err != nil {
+			t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+		}
+
+		return v
+	}
+
+	get()
+
+	clk.Add(int64(500 * time.Millisecond))
+	if act := get(); act != int32(1) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 1", act)
+	}
+
+	// items are refreshed in the background once they are stale
+	clk.Add(int64(495 * time.Millisecond))
+	if act := get(); act != int32(1) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 1", act)
+	}
+
+	var act interface{}
+	for r := 0; r < 100 && act != int32(2); r++ {
+		time.Sleep(1 * time.Millisecond)
+		act = get()
+	}
+	if act != int32(2) {
+		t.Errorf("GetOrAddFunc(); got %v, expected 2", act)
+	}
+}
+
+// fixedSource is a rand.Source that always returns the same value
+type fixedSource int64
+
+func (s fixedSource) Int63() int64 {
+	return int64(s)
+}
+
+func (s fixedSource) Seed(int64) {}
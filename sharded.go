@@ -0,0 +1,538 @@
+package lru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ShardedCache represents a sharded LRU memory cache
+type ShardedCache = TypedShardedCache[string, interface{}]
+
+// NewShardedCache returns a new sharded LRU cache with the specified number of shards
+func NewShardedCache(o Options, shards int) *ShardedCache {
+	return NewTypedShardedCache(o, shards)
+}
+
+// NewTypedShardedCache returns a new typed sharded LRU cache with the specified number
+// of shards. The capacity and size limit are divided across the shards, each of which
+// has its own lock.
+func NewTypedShardedCache[K comparable, V any](o TypedOptions[K, V], shards int) *TypedShardedCache[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+
+	cap := capacityOrDefault(o.Capacity, o.MaxBytes)
+
+	c := &TypedShardedCache[K, V]{
+		ItemEvicted:  func(*TypedItem[K, V], EvictReason) {},
+		ItemAdded:    func(*TypedItem[K, V]) {},
+		CanEvict:     func(*TypedItem[K, V]) bool { return true },
+		OnHit:        func(K) {},
+		OnMiss:       func(K) {},
+		OnCreate:     func(K, time.Duration) {},
+		OnThrash:     func(float64) {},
+		OnStore:      func(_ K, v V) (V, error) { return v, nil },
+		OnCloseError: func(K, error) {},
+		shards:       make([]*TypedCache[K, V], shards),
+		seed:         maphash.MakeSeed(),
+	}
+
+	for idx := range c.shards {
+		so := o
+		so.Capacity = shardCapacity(cap, shards, idx)
+		if o.Rand != nil {
+			// each shard has its own source as the source is used under the shard lock
+			so.Rand = rand.New(rand.NewSource(o.Rand.Int63()))
+		}
+		if o.InitialCapacity > 0 {
+			so.InitialCapacity = o.InitialCapacity / shards
+		}
+		if o.GhostSize > 0 {
+			so.GhostSize = max(o.GhostSize/shards, 1)
+		}
+		if o.MaxBytes > 0 {
+			so.MaxBytes = o.MaxBytes / int64(shards)
+			if so.MaxBytes < 1 {
+				so.MaxBytes = 1
+			}
+		}
+
+		s := NewTypedCache(so)
+		if c.creates == nil {
+			c.creates = s.creates
+		}
+
+		// the create func limit applies to all shards
+		s.creates = c.creates
+		s.ItemEvicted = func(i *TypedItem[K, V], r EvictReason) {
+			c.ItemEvicted(i, r)
+		}
+		s.ItemsEvicted = func(is []*TypedItem[K, V], r EvictReason) {
+			if c.ItemsEvicted == nil {
+				for _, i := range is {
+					c.ItemEvicted(i, r)
+				}
+
+				return
+			}
+
+			c.ItemsEvicted(is, r)
+		}
+		s.ItemAdded = func(i *TypedItem[K, V]) {
+			c.ItemAdded(i)
+		}
+		s.CanEvict = func(i *TypedItem[K, V]) bool {
+			return c.CanEvict(i)
+		}
+		s.OnHit = func(k K) {
+			c.OnHit(k)
+		}
+		s.OnMiss = func(k K) {
+			c.OnMiss(k)
+		}
+		s.OnCreate = func(k K, d time.Duration) {
+			c.OnCreate(k, d)
+		}
+		s.OnThrash = func(rate float64) {
+			c.OnThrash(rate)
+		}
+		s.OnStore = func(k K, v V) (V, error) {
+			return c.OnStore(k, v)
+		}
+		s.OnCloseError = func(k K, err error) {
+			c.OnCloseError(k, err)
+		}
+		s.OnLockWait = func(d time.Duration) {
+			if c.OnLockWait != nil {
+				c.OnLockWait(d)
+			}
+		}
+
+		c.shards[idx] = s
+	}
+
+	return c
+}
+
+// TypedShardedCache represents a typed sharded LRU memory cache. Keys are hashed
+// to a shard and eviction is least recently used within each shard.
+type TypedShardedCache[K comparable, V any] struct {
+	ItemEvicted func(*TypedItem[K, V], EvictReason)
+
+	// ItemsEvicted is invoked with the items removed by a bulk operation for each shard
+	ItemsEvicted func([]*TypedItem[K, V], EvictReason)
+
+	// ItemAdded is invoked while the shard lock is held, so it must not call any cache methods
+	ItemAdded func(*TypedItem[K, V])
+	CanEvict  func(*TypedItem[K, V]) bool
+	OnHit     func(K)
+	OnMiss    func(K)
+	OnCreate  func(K, time.Duration)
+
+	// OnThrash is invoked with the eviction rate of each ThrashWindow of a shard that
+	// reaches ThrashRate
+	OnThrash func(rate float64)
+	OnStore  func(K, V) (V, error)
+
+	// OnLockWait is invoked with the time spent waiting to acquire a shard lock if it
+	// exceeds LockWaitThreshold. OnLockWait is nil by default.
+	OnLockWait func(time.Duration)
+
+	// OnCloseError is invoked if closing a value fails when AutoCloseValues is set
+	OnCloseError func(K, error)
+
+	shards  []*TypedCache[K, V]
+	creates chan struct{}
+	seed    maphash.Seed
+}
+
+// GetOrAdd returns the cached item with the request key if it exists.
+// If the key does not exist then the create func is invoked and the result cached.
+func (c *TypedShardedCache[K, V]) GetOrAdd(r *TypedGetOrAdd[K, V]) error {
+	return c.shard(r.Key).GetOrAdd(r)
+}
+
+// GetOrAddFunc is equivalent to GetOrAdd, but builds the request from the specified
+// key, TTL and create func and returns the result
+func (c *TypedShardedCache[K, V]) GetOrAddFunc(key K, ttl time.Duration, create func() (V, error)) (V, error) {
+	return c.shard(key).GetOrAddFunc(key, ttl, create)
+}
+
+// Get returns the cached value for the specified key if it exists. If the key does not
+// exist then Options.Loader is invoked and the result cached with the specified TTL.
+func (c *TypedShardedCache[K, V]) Get(key K, ttl time.Duration) (V, error) {
+	return c.shard(key).Get(key, ttl)
+}
+
+// Warm loads the specified keys concurrently using the loader func and caches the
+// results with the specified TTL. The loader errors are joined and returned.
+func (c *TypedShardedCache[K, V]) Warm(keys []K, loader func(K) (V, error), ttl time.Duration) error {
+	return warm(keys, func(k K) error {
+		_, err := c.GetOrAddFunc(k, ttl, func() (V, error) {
+			return loader(k)
+		})
+		return err
+	})
+}
+
+// GetOrAddWithTTLFunc is equivalent to GetOrAdd, but builds the request from the
+// specified key and create func, which returns the TTL for the created value
+func (c *TypedShardedCache[K, V]) GetOrAddWithTTLFunc(key K, create func() (V, time.Duration, error)) (V, error) {
+	return c.shard(key).GetOrAddWithTTLFunc(key, create)
+}
+
+// GetOrLoad is equivalent to GetOrAddFunc, but the value is created by invoking the
+// loaders in order until one of them finds the key
+func (c *TypedShardedCache[K, V]) GetOrLoad(key K, ttl time.Duration, loaders ...func(K) (V, bool, error)) (V, error) {
+	return c.shard(key).GetOrLoad(key, ttl, loaders...)
+}
+
+// GetOrAddOnce is equivalent to GetOrAddFunc, but the created value does not expire
+// and is pinned, so that the create func is invoked at most once for the key
+func (c *TypedShardedCache[K, V]) GetOrAddOnce(key K, create func() (V, error)) (V, error) {
+	return c.shard(key).GetOrAddOnce(key, create)
+}
+
+// GetOrAddContext is equivalent to GetOrAdd, but returns the context error if the
+// context is cancelled before the result is available
+func (c *TypedShardedCache[K, V]) GetOrAddContext(ctx context.Context, r *TypedGetOrAdd[K, V]) error {
+	return c.shard(r.Key).GetOrAddContext(ctx, r)
+}
+
+// GetMulti returns the values for the live items with the specified keys. The lock
+// for each shard is acquired once.
+func (c *TypedShardedCache[K, V]) GetMulti(keys []K) map[K]V {
+	sk := map[*TypedCache[K, V]][]K{}
+	for _, k := range keys {
+		s := c.shard(k)
+		sk[s] = append(sk[s], k)
+	}
+
+	res := make(map[K]V, len(keys))
+	for s, ks := range sk {
+		for k, v := range s.GetMulti(ks) {
+			res[k] = v
+		}
+	}
+
+	return res
+}
+
+// Set adds the value to the cache with the specified key and TTL.
+// If the key already exists then the value and expiry are replaced and the previous
+// value is returned with true if the item had not expired.
+func (c *TypedShardedCache[K, V]) Set(key K, value V, ttl time.Duration) (V, bool) {
+	return c.shard(key).Set(key, value, ttl)
+}
+
+// SetMulti adds the item keys and values to the cache with the specified TTL. The lock
+// for each shard is acquired once, or once per batch if Options.BatchSize is set, and
+// items are added to each shard in order.
+func (c *TypedShardedCache[K, V]) SetMulti(items []TypedItem[K, V], ttl time.Duration) {
+	si := map[*TypedCache[K, V]][]TypedItem[K, V]{}
+	for _, i := range items {
+		s := c.shard(i.Key)
+		si[s] = append(si[s], TypedItem[K, V]{Key: i.Key, Value: i.Value})
+	}
+
+	for s, is := range si {
+		s.SetMulti(is, ttl)
+	}
+}
+
+// UpdateValue replaces the value of the item with the specified key without updating
+// the item expiry and returns true if the key exists and the item has not expired.
+func (c *TypedShardedCache[K, V]) UpdateValue(key K, value V) bool {
+	return c.shard(key).UpdateValue(key, value)
+}
+
+// CompareAndSwap replaces the value of the item with the specified key if the current
+// value is equal to old and returns true if the value was replaced
+func (c *TypedShardedCache[K, V]) CompareAndSwap(key K, old, new V) bool {
+	return c.shard(key).CompareAndSwap(key, old, new)
+}
+
+// SetPolicy replaces the expiration policy for all shards. Existing items retain their
+// expiry, which is applied by the new policy from the next access.
+func (c *TypedShardedCache[K, V]) SetPolicy(p TypedExpirationPolicy[K, V]) {
+	for _, s := range c.shards {
+		s.SetPolicy(p)
+	}
+}
+
+// GetStale returns the value of the item with the specified key, true if the item has
+// expired and true if the key exists. Expired items are not removed.
+func (c *TypedShardedCache[K, V]) GetStale(key K) (value V, stale bool, ok bool) {
+	return c.shard(key).GetStale(key)
+}
+
+// Contains returns true if the cache contains a non-expired item with the specified key.
+// The item recency and expiry are not updated.
+func (c *TypedShardedCache[K, V]) Contains(key K) bool {
+	return c.shard(key).Contains(key)
+}
+
+// TTL returns the time remaining until the item with the specified key expires and true
+// if the key exists and the item has not expired. A zero duration is returned for items
+// that do not expire.
+func (c *TypedShardedCache[K, V]) TTL(key K) (time.Duration, bool) {
+	return c.shard(key).TTL(key)
+}
+
+// Touch applies the expiration policy to the item with the specified key and records
+// an access. It returns true if the key exists and the item has not expired.
+func (c *TypedShardedCache[K, V]) Touch(key K) bool {
+	return c.shard(key).Touch(key)
+}
+
+// Pin marks the item with the specified key as exempt from capacity eviction and returns
+// true if the key exists
+func (c *TypedShardedCache[K, V]) Pin(key K) bool {
+	return c.shard(key).Pin(key)
+}
+
+// Unpin removes the capacity eviction exemption for the item with the specified key and
+// returns true if the key exists
+func (c *TypedShardedCache[K, V]) Unpin(key K) bool {
+	return c.shard(key).Unpin(key)
+}
+
+// Remove removes the item with the specified key from the cache and returns true
+// if it existed. ItemEvicted is invoked for the removed item.
+func (c *TypedShardedCache[K, V]) Remove(key K) bool {
+	return c.shard(key).Remove(key)
+}
+
+// Take removes the item with the specified key and returns its value and true if it
+// had not expired
+func (c *TypedShardedCache[K, V]) Take(key K) (V, bool) {
+	return c.shard(key).Take(key)
+}
+
+// RemoveFunc removes each non-expired item for which the func returns true and returns
+// the number of items removed. The lock for each shard is held while the func is invoked
+// for the shard items, so it must not call any cache methods.
+func (c *TypedShardedCache[K, V]) RemoveFunc(fn func(key K, value V) bool) int {
+	var n int
+	for _, s := range c.shards {
+		n += s.RemoveFunc(fn)
+	}
+
+	return n
+}
+
+// InvalidateTag removes all items with the specified tag from all shards and returns the number of items removed
+func (c *TypedShardedCache[K, V]) InvalidateTag(tag string) int {
+	var n int
+	for _, s := range c.shards {
+		n += s.InvalidateTag(tag)
+	}
+
+	return n
+}
+
+// RemoveExpired removes all expired items from all shards and returns the number of items removed
+func (c *TypedShardedCache[K, V]) RemoveExpired() int {
+	var n int
+	for _, s := range c.shards {
+		n += s.RemoveExpired()
+	}
+
+	return n
+}
+
+// Len returns the total number of items in all shards
+func (c *TypedShardedCache[K, V]) Len() int {
+	var n int
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+
+	return n
+}
+
+// Capacity returns the total capacity of all shards. If the capacity is unlimited or
+// only MaxBytes was specified then math.MaxInt is returned.
+func (c *TypedShardedCache[K, V]) Capacity() int {
+	var n int
+	for _, s := range c.shards {
+		sc := s.Capacity()
+		if sc > math.MaxInt-n {
+			return math.MaxInt
+		}
+
+		n += sc
+	}
+
+	return n
+}
+
+// Keys returns the cached keys for all shards. Keys are ordered from least to most
+// recently used within each shard, but are not ordered across shards.
+func (c *TypedShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, s := range c.shards {
+		keys = append(keys, s.Keys()...)
+	}
+
+	return keys
+}
+
+// Values returns the values of the non-expired items in each shard. Values are ordered
+// within each shard, but not across shards.
+func (c *TypedShardedCache[K, V]) Values() []V {
+	var vs []V
+	for _, s := range c.shards {
+		vs = append(vs, s.Values()...)
+	}
+
+	return vs
+}
+
+// Range invokes the func for each non-expired item in each shard until the func
+// returns false. Items are ordered within each shard, but not across shards.
+// The func must not call any cache methods.
+func (c *TypedShardedCache[K, V]) Range(fn func(key K, value V) bool) {
+	for _, s := range c.shards {
+		ok := true
+		s.Range(func(k K, v V) bool {
+			ok = fn(k, v)
+			return ok
+		})
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of each non-expired item in each shard. Items are ordered
+// within each shard, but not across shards, and each shard is copied separately.
+func (c *TypedShardedCache[K, V]) Snapshot() []TypedItem[K, V] {
+	var is []TypedItem[K, V]
+	for _, s := range c.shards {
+		is = append(is, s.Snapshot()...)
+	}
+
+	return is
+}
+
+// CheckInvariants returns an error for each shard with an inconsistent internal state
+func (c *TypedShardedCache[K, V]) CheckInvariants() error {
+	var errs []error
+	for idx, s := range c.shards {
+		if err := s.CheckInvariants(); err != nil {
+			errs = append(errs, fmt.Errorf("shard %d: %w", idx, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// String returns a summary of the total length and capacity of the shards
+func (c *TypedShardedCache[K, V]) String() string {
+	return fmt.Sprintf("lru.ShardedCache{shards: %d, len: %d, capacity: %d}", len(c.shards), c.Len(), c.Capacity())
+}
+
+// Dump returns the cached keys and expiry times of each shard in shard order
+func (c *TypedShardedCache[K, V]) Dump() string {
+	var d string
+	for _, s := range c.shards {
+		d += s.Dump()
+	}
+
+	return d
+}
+
+// Clear removes all items from all shards
+func (c *TypedShardedCache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.Clear()
+	}
+}
+
+// Resize divides the capacity across the shards and returns the total number of items
+// evicted to fit within it. If the capacity is zero then the default capacity is used
+// and if it is negative then the capacity is unlimited, as with NewShardedCache.
+func (c *TypedShardedCache[K, V]) Resize(capacity int) int {
+	cap := capacityOrDefault(capacity, c.shards[0].maxBytes)
+
+	var n int
+	for idx, s := range c.shards {
+		n += s.Resize(shardCapacity(cap, len(c.shards), idx))
+	}
+
+	return n
+}
+
+// Close closes all shards. The first error is returned.
+func (c *TypedShardedCache[K, V]) Close() error {
+	var err error
+	for _, s := range c.shards {
+		if serr := s.Close(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// Stats returns the combined statistics for all shards
+func (c *TypedShardedCache[K, V]) Stats() Stats {
+	var st Stats
+	var total time.Duration
+	for _, s := range c.shards {
+		ss := s.Stats()
+
+		st.Hits += ss.Hits
+		st.Misses += ss.Misses
+		st.Evictions += ss.Evictions
+		st.GhostHits += ss.GhostHits
+		st.Len += ss.Len
+		st.Bytes += ss.Bytes
+
+		total += ss.AvgEvictionAge * time.Duration(ss.Evictions)
+		if ss.MaxEvictionAge > st.MaxEvictionAge {
+			st.MaxEvictionAge = ss.MaxEvictionAge
+		}
+	}
+	if st.Evictions > 0 {
+		st.AvgEvictionAge = total / time.Duration(st.Evictions)
+	}
+
+	return st
+}
+
+// ResetStats resets the statistic counters for all shards
+func (c *TypedShardedCache[K, V]) ResetStats() {
+	for _, s := range c.shards {
+		s.ResetStats()
+	}
+}
+
+func (c *TypedShardedCache[K, V]) shard(key K) *TypedCache[K, V] {
+	return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+// shardCapacity returns the capacity for the shard at the specified index. The
+// capacity is not divided if the number of items is not limited.
+func shardCapacity(capacity, shards, idx int) int {
+	if capacity == math.MaxInt {
+		return capacity
+	}
+
+	n := capacity / shards
+	if idx < capacity%shards {
+		n++
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	return n
+}
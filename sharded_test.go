@@ -0,0 +1,135 @@
+package lru_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestShardedCache(t *testing.T) {
+	tests := []struct {
+		capacity  int
+		shards    int
+		keys      int
+		evictions bool
+	}{
+		{
+			capacity:  100,
+			shards:    4,
+			keys:      10,
+			evictions: false,
+		},
+		{
+			capacity:  10,
+			shards:    4,
+			keys:      100,
+			evictions: true,
+		},
+		{
+			capacity:  2,
+			shards:    0,
+			keys:      2,
+			evictions: false,
+		},
+	}
+
+	for tn, tt := range tests {
+		evictions := 0
+
+		c := lru.NewShardedCache(lru.Options{
+			Capacity: tt.capacity,
+		}, tt.shards)
+
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			evictions++
+		}
+
+		for idx := 0; idx < tt.keys; idx++ {
+			exp := fmt.Sprintf("value:%d", idx)
+			req := lru.GetOrAdd{
+				Key: fmt.Sprintf("key:%d", idx),
+				Create: func() (interface{}, error) {
+					return exp, nil
+				},
+			}
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+			if req.Result != exp {
+				t.Errorf("GetOrAdd(%d); got %v, expected %s", tn, req.Result, exp)
+			}
+		}
+
+		if act := c.Capacity(); act != tt.capacity {
+			t.Errorf("Capacity(%d); got %d, expected %d", tn, act, tt.capacity)
+		}
+		if act := c.Len(); act > tt.capacity {
+			t.Errorf("Len(%d); got %d, expected <= %d", tn, act, tt.capacity)
+		}
+		if act := len(c.Keys()); act != c.Len() {
+			t.Errorf("Keys(%d); got %d keys, expected %d", tn, act, c.Len())
+		}
+		if (evictions > 0) != tt.evictions {
+			t.Errorf("GetOrAdd(%d); got %d evictions, expected evictions %v", tn, evictions, tt.evictions)
+		}
+		if st := c.Stats(); st.Evictions != uint64(evictions) || st.Len != c.Len() {
+			t.Errorf("Stats(%d); got %+v, expected %d evictions", tn, st, evictions)
+		}
+
+		n := c.Len()
+		if act := c.Resize(1); act != n-c.Len() {
+			t.Errorf("Resize(%d); got %d, expected %d", tn, act, n-c.Len())
+		}
+		if act := c.Len(); act > max(tt.shards, 1) {
+			t.Errorf("Resize(%d); got %d items, expected <= %d", tn, act, max(tt.shards, 1))
+		}
+
+		c.Clear()
+		if act := c.Len(); act != 0 {
+			t.Errorf("Clear(%d); got %d items, expected 0", tn, act)
+		}
+	}
+}
+
+func TestShardedCacheParallel(t *testing.T) {
+	c := lru.NewShardedCache(lru.Options{
+		Capacity: 100,
+	}, 8)
+
+	wg := new(sync.WaitGroup)
+
+	for r := 0; r < 100; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for o := 0; o < 100; o++ {
+				key := fmt.Sprintf("key:%d", o)
+
+				c.Set(key, o, 0)
+				c.Contains(key)
+				c.Remove(key)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestShardedCacheCheckInvariants(t *testing.T) {
+	c := lru.NewShardedCache(lru.Options{Capacity: 16}, 4)
+
+	for idx := 0; idx < 100; idx++ {
+		c.Set(fmt.Sprintf("key_%d", idx%32), idx, 0)
+		if idx%3 == 0 {
+			c.Remove(fmt.Sprintf("key_%d", idx%7))
+		}
+	}
+
+	if err := c.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants(); got %v, expected nil", err)
+	}
+}
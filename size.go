@@ -0,0 +1,102 @@
+package lru
+
+import (
+	"math"
+	"reflect"
+)
+
+// maxSizeDepth is the number of pointers, interfaces and nested values that are
+// followed when estimating a value size, which also prevents cycles being followed
+const maxSizeDepth = 8
+
+// EstimateSize returns a best-effort estimate of the size of the value in bytes. Strings
+// and byte slices are sized by their length, and slices, arrays, maps and structs by the sum
+// of the estimates of their elements or fields. Other values are sized by their type size
+// and pointers and interfaces are followed to the value they refer to. The estimate does not
+// include allocation or map overhead, so it is not exact, but it allows MaxBytes to bound the
+// memory used by values such as strings without a custom Sizer.
+func EstimateSize(v any) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+
+	return estimateSize(reflect.ValueOf(v), maxSizeDepth)
+}
+
+// estimateSize returns the estimated size of the value, following nested values
+// until the depth is exhausted
+func estimateSize(v reflect.Value, depth int) int64 {
+	if depth < 1 {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+
+		return estimateSize(v.Elem(), depth-1)
+	case reflect.Slice, reflect.Array:
+		if hasFixedSize(v.Type().Elem()) {
+			return int64(v.Len()) * int64(v.Type().Elem().Size())
+		}
+
+		var n int64
+		for idx := 0; idx < v.Len(); idx++ {
+			n = addSize(n, estimateSize(v.Index(idx), depth-1))
+		}
+
+		return n
+	case reflect.Map:
+		var n int64
+		for it := v.MapRange(); it.Next(); {
+			n = addSize(n, estimateSize(it.Key(), depth-1))
+			n = addSize(n, estimateSize(it.Value(), depth-1))
+		}
+
+		return n
+	case reflect.Struct:
+		var n int64
+		for idx := 0; idx < v.NumField(); idx++ {
+			n = addSize(n, estimateSize(v.Field(idx), depth-1))
+		}
+
+		return n
+	default:
+		return int64(v.Type().Size())
+	}
+}
+
+// hasFixedSize returns true if values of the type do not refer to other values, so the
+// type size is the size of the value
+func hasFixedSize(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return hasFixedSize(t.Elem())
+	default:
+		return false
+	}
+}
+
+// addSize returns the sum of the sizes, limited to math.MaxInt64
+func addSize(a, b int64) int64 {
+	if b > math.MaxInt64-a {
+		return math.MaxInt64
+	}
+
+	return a + b
+}
@@ -0,0 +1,85 @@
+package lru_test
+
+import (
+	"fmt"
+	"testing"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestEstimateSize(t *testing.T) {
+	type value struct {
+		name string
+		tags []string
+		id   int64
+	}
+
+	type node struct {
+		next *node
+		id   int32
+	}
+
+	cyclic := &node{id: 1}
+	cyclic.next = cyclic
+
+	tests := []struct {
+		value interface{}
+		exp   int64
+	}{
+		{value: nil, exp: 0},
+		{value: "abc", exp: 3},
+		{value: []byte("abcd"), exp: 4},
+		{value: int64(1), exp: 8},
+		{value: []int32{1, 2, 3}, exp: 12},
+		{value: [2]uint16{1, 2}, exp: 4},
+		{value: []string{"ab", "cde"}, exp: 5},
+		{value: map[string]string{"a": "bc", "de": "f"}, exp: 6},
+		{value: value{name: "abc", tags: []string{"d", "ef"}, id: 1}, exp: 14},
+		{value: &value{name: "abc"}, exp: 11},
+		{value: (*value)(nil), exp: 0},
+		{value: []interface{}{"ab", int16(1), nil}, exp: 4},
+		{value: cyclic, exp: 12},
+	}
+
+	for tn, tt := range tests {
+		if act := lru.EstimateSize(tt.value); act != tt.exp {
+			t.Errorf("EstimateSize(%d); got %d, expected %d", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheWithEstimatedSize(t *testing.T) {
+	tests := []struct {
+		sizer   func(interface{}) int64
+		evicted []string
+	}{
+		{
+			sizer:   nil,
+			evicted: []string{"key_1"},
+		},
+		{
+			sizer:   func(interface{}) int64 { return 1 },
+			evicted: []string{},
+		},
+	}
+
+	for tn, tt := range tests {
+		evicted := []string{}
+
+		c := lru.NewCache(lru.Options{
+			MaxBytes: 10,
+			Sizer:    tt.sizer,
+		})
+		c.ItemEvicted = func(i *lru.Item, _ lru.EvictReason) {
+			evicted = append(evicted, i.Key)
+		}
+
+		c.Set("key_1", "abcd", 0)
+		c.Set("key_2", "efgh", 0)
+		c.Set("key_3", "ijkl", 0)
+
+		if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+			t.Errorf("ItemEvicted(%d); got %v, expected %v", tn, evicted, tt.evicted)
+		}
+	}
+}
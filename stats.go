@@ -0,0 +1,146 @@
+package lru
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Stats represents a snapshot of cache statistics. Stats are marshalled to JSON with
+// durations in nanoseconds and include the hit ratio.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Len       int    `json:"len"`
+	Bytes     int64  `json:"bytes"`
+
+	// GhostHits is the number of misses for keys in the ghost list, which were recently
+	// evicted to make room for a new item. It is only counted if Options.GhostSize is set.
+	GhostHits uint64 `json:"ghost_hits"`
+
+	// AvgEvictionAge and MaxEvictionAge are the average and maximum time between
+	// an item being added and evicted to make room for a new item
+	AvgEvictionAge time.Duration `json:"avg_eviction_age_ns"`
+	MaxEvictionAge time.Duration `json:"max_eviction_age_ns"`
+}
+
+// HitRatio returns the ratio of hits to lookups, or zero if there have been no lookups
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// MarshalJSON marshals the stats with the hit ratio
+func (s Stats) MarshalJSON() ([]byte, error) {
+	// the alias type does not have the method, which avoids recursion
+	type stats Stats
+
+	return json.Marshal(struct {
+		stats
+		HitRatio float64 `json:"hit_ratio"`
+	}{
+		stats:    stats(s),
+		HitRatio: s.HitRatio(),
+	})
+}
+
+// Stats returns a snapshot of the cache statistics. Misses are counted for each
+// request that does not find a live item and evictions are counted when the
+// least recently used item is removed to make room for a new item. The lock is
+// held while the counters are read, including by hits that are served under the
+// read lock, so that the snapshot is consistent.
+func (c *TypedCache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := Stats{
+		Hits:           c.stats.hits.Load(),
+		Misses:         c.stats.misses.Load(),
+		Evictions:      c.stats.evictions.Load(),
+		GhostHits:      c.stats.ghostHits.Load(),
+		Len:            len(c.items),
+		Bytes:          c.bytes,
+		MaxEvictionAge: time.Duration(c.stats.maxAge.Load()),
+	}
+	if st.Evictions > 0 {
+		st.AvgEvictionAge = time.Duration(c.stats.totalAge.Load() / int64(st.Evictions))
+	}
+
+	return st
+}
+
+// ResetStats resets the cache statistic counters
+func (c *TypedCache[K, V]) ResetStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.hits.Store(0)
+	c.stats.misses.Store(0)
+	c.stats.evictions.Store(0)
+	c.stats.ghostHits.Store(0)
+	c.stats.totalAge.Store(0)
+	c.stats.maxAge.Store(0)
+	c.stats.lookups.Store(0)
+	c.stats.windowEvictions.Store(0)
+}
+
+// checkThrash records a GetOrAdd lookup and invokes OnThrash if the lookup completes
+// a window with an eviction rate of at least the threshold. It must not be invoked
+// while the lock is held.
+func (c *TypedCache[K, V]) checkThrash() {
+	if c.thrashWindow < 1 || c.stats.lookups.Add(1)%uint64(c.thrashWindow) != 0 {
+		return
+	}
+
+	ev := c.stats.evictions.Load()
+	prev := c.stats.windowEvictions.Swap(ev)
+	if ev < prev {
+		// the stats were reset during the window
+		return
+	}
+
+	if rate := float64(ev-prev) / float64(c.thrashWindow); rate >= c.thrashRate {
+		c.OnThrash(rate)
+	}
+}
+
+// counters represents the cache statistic counters. Counters are updated atomically
+// as hits can be recorded under the read lock.
+type counters struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+	ghostHits atomic.Uint64
+	totalAge  atomic.Int64
+	maxAge    atomic.Int64
+
+	// lookups and windowEvictions are the GetOrAdd lookups and the evictions at the
+	// start of the current window, which are used to calculate the eviction rate
+	lookups         atomic.Uint64
+	windowEvictions atomic.Uint64
+}
+
+// recordMiss records a miss for the specified key, counting a ghost hit and removing
+// the key from the ghost list if it was recently evicted. The caller must hold the lock.
+func (c *TypedCache[K, V]) recordMiss(key K) {
+	c.stats.misses.Add(1)
+
+	if c.ghosts != nil && c.ghosts.remove(key) {
+		c.stats.ghostHits.Add(1)
+	}
+}
+
+// recordEviction records a capacity eviction of an item with the specified age.
+// The caller must hold the lock.
+func (c *counters) recordEviction(age time.Duration) {
+	c.evictions.Add(1)
+	c.totalAge.Add(int64(age))
+
+	if int64(age) > c.maxAge.Load() {
+		c.maxAge.Store(int64(age))
+	}
+}
@@ -0,0 +1,425 @@
+package lru_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestCacheStats(t *testing.T) {
+	tests := []struct {
+		capacity int
+		keys     []string
+		exp      lru.Stats
+	}{
+		{
+			capacity: 2,
+			keys:     []string{"key_1", "key_1", "key_1"},
+			exp:      lru.Stats{Hits: 2, Misses: 1, Evictions: 0, Len: 1},
+		},
+		{
+			capacity: 1,
+			keys:     []string{"key_1", "key_2", "key_1"},
+			exp:      lru.Stats{Hits: 0, Misses: 3, Evictions: 2, Len: 1},
+		},
+		{
+			capacity: 2,
+			keys:     []string{"key_1", "key_2", "key_1", "key_3", "key_2"},
+			exp:      lru.Stats{Hits: 1, Misses: 4, Evictions: 2, Len: 2},
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity: tt.capacity,
+		})
+
+		// fix the time so that eviction ages are zero
+		fixTime(time.Now().UTC(), func() {
+			for _, k := range tt.keys {
+				req := lru.GetOrAdd{
+					Key: k,
+					Create: func() (interface{}, error) {
+						return k, nil
+					},
+				}
+
+				if err := c.GetOrAdd(&req); err != nil {
+					t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+				}
+			}
+		})
+
+		if act := c.Stats(); act != tt.exp {
+			t.Errorf("Stats(%d); got %+v, expected %+v", tn, act, tt.exp)
+		}
+
+		c.ResetStats()
+
+		exp := lru.Stats{Len: tt.exp.Len}
+		if act := c.Stats(); act != exp {
+			t.Errorf("ResetStats(%d); got %+v, expected %+v", tn, act, exp)
+		}
+	}
+}
+
+func TestCacheGhostHits(t *testing.T) {
+	tests := []struct {
+		ghostSize int
+		keys      []string
+		exp       uint64
+	}{
+		{
+			ghostSize: 0,
+			keys:      []string{"key_1", "key_2", "key_1"},
+			exp:       0,
+		},
+		{
+			ghostSize: 1,
+			keys:      []string{"key_1", "key_2", "key_1"},
+			exp:       1,
+		},
+		{
+			ghostSize: 1,
+			keys:      []string{"key_1", "key_2", "key_3", "key_1"},
+			exp:       0,
+		},
+		{
+			ghostSize: 2,
+			keys:      []string{"key_1", "key_2", "key_3", "key_1", "key_2"},
+			exp:       2,
+		},
+		{
+			ghostSize: 2,
+			keys:      []string{"key_1", "key_1", "key_1"},
+			exp:       0,
+		},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity:  1,
+			GhostSize: tt.ghostSize,
+		})
+
+		for _, k := range tt.keys {
+			if _, err := c.GetOrAddFunc(k, 0, func() (interface{}, error) {
+				return k, nil
+			}); err != nil {
+				t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		if act := c.Stats().GhostHits; act != tt.exp {
+			t.Errorf("Stats(%d); got %d, expected %d", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestCacheStatsNegativeEviction(t *testing.T) {
+	c := lru.NewCache(lru.Options{
+		Capacity:    1,
+		NegativeTTL: 1 * time.Minute,
+		GhostSize:   1,
+	})
+
+	createErr := errors.New("error")
+	if _, err := c.GetOrAddFunc("key_1", 0, func() (interface{}, error) {
+		return nil, createErr
+	}); err != createErr {
+		t.Errorf("GetOrAddFunc(); got %v, expected %v", err, createErr)
+	}
+
+	// the negative entry is evicted, but is not counted or recorded in the ghost list
+	c.Set("key_2", "value", 0)
+
+	if _, err := c.GetOrAddFunc("key_1", 0, func() (interface{}, error) {
+		return "value", nil
+	}); err != nil {
+		t.Errorf("GetOrAddFunc(); got %v, expected nil", err)
+	}
+
+	st := c.Stats()
+	if st.Evictions != 1 {
+		t.Errorf("Stats(); got %d evictions, expected %d", st.Evictions, 1)
+	}
+	if st.GhostHits != 0 {
+		t.Errorf("Stats(); got %d ghost hits, expected %d", st.GhostHits, 0)
+	}
+}
+
+func TestCacheHooks(t *testing.T) {
+	tests := []struct {
+		eviction func() lru.EvictionPolicy
+		keys     []string
+		hits     []string
+		misses   []string
+	}{
+		{
+			keys:   []string{"key_1", "key_1", "key_2"},
+			hits:   []string{"key_1"},
+			misses: []string{"key_1", "key_2"},
+		},
+		{
+			eviction: lru.NewApproximateLRUEvictionPolicy,
+			keys:     []string{"key_1", "key_1", "key_2"},
+			hits:     []string{"key_1"},
+			misses:   []string{"key_1", "key_2"},
+		},
+		{
+			keys:   []string{"key_1", "key_2", "key_3", "key_1"},
+			hits:   nil,
+			misses: []string{"key_1", "key_2", "key_3", "key_1"},
+		},
+	}
+
+	for tn, tt := range tests {
+		var hits, misses []string
+
+		c := lru.NewCache(lru.Options{
+			Capacity: 2,
+			Eviction: tt.eviction,
+		})
+		c.OnHit = func(k string) {
+			hits = append(hits, k)
+		}
+		c.OnMiss = func(k string) {
+			misses = append(misses, k)
+		}
+
+		for _, k := range tt.keys {
+			req := lru.GetOrAdd{
+				Key: k,
+				Create: func() (interface{}, error) {
+					return k, nil
+				},
+			}
+
+			if err := c.GetOrAdd(&req); err != nil {
+				t.Errorf("GetOrAdd(%d); got %v, expected nil", tn, err)
+			}
+		}
+
+		if fmt.Sprint(hits) != fmt.Sprint(tt.hits) {
+			t.Errorf("OnHit(%d); got %v, expected %v", tn, hits, tt.hits)
+		}
+		if fmt.Sprint(misses) != fmt.Sprint(tt.misses) {
+			t.Errorf("OnMiss(%d); got %v, expected %v", tn, misses, tt.misses)
+		}
+	}
+}
+
+func TestCacheStatsEvictionAge(t *testing.T) {
+	now := time.Now().UTC()
+	clk := now
+
+	c := lru.NewCache(lru.Options{
+		Capacity: 1,
+		Clock: lru.ClockFunc(func() time.Time {
+			return clk
+		}),
+	})
+
+	c.Set("key_1", 1, 0)
+	clk = now.Add(10 * time.Second)
+	c.Set("key_2", 2, 0)
+	clk = now.Add(40 * time.Second)
+	c.Set("key_3", 3, 0)
+
+	st := c.Stats()
+	if exp := 20 * time.Second; st.AvgEvictionAge != exp {
+		t.Errorf("Stats(); got %v average age, expected %v", st.AvgEvictionAge, exp)
+	}
+	if exp := 30 * time.Second; st.MaxEvictionAge != exp {
+		t.Errorf("Stats(); got %v maximum age, expected %v", st.MaxEvictionAge, exp)
+	}
+
+	c.ResetStats()
+
+	if st := c.Stats(); st.AvgEvictionAge != 0 || st.MaxEvictionAge != 0 {
+		t.Errorf("ResetStats(); got %+v, expected zero ages", st)
+	}
+}
+
+func TestCacheOnCreate(t *testing.T) {
+	var keys []string
+	var durations []time.Duration
+
+	c := lru.NewCache(lru.Options{})
+	c.OnCreate = func(k string, d time.Duration) {
+		keys = append(keys, k)
+		durations = append(durations, d)
+	}
+
+	for _, k := range []string{"key_1", "key_1", "key_2"} {
+		req := lru.GetOrAdd{
+			Key: k,
+			Create: func() (interface{}, error) {
+				time.Sleep(10 * time.Millisecond)
+				return k, nil
+			},
+		}
+
+		if err := c.GetOrAdd(&req); err != nil {
+			t.Errorf("GetOrAdd(); got %v, expected nil", err)
+		}
+	}
+
+	if exp := []string{"key_1", "key_2"}; fmt.Sprint(keys) != fmt.Sprint(exp) {
+		t.Errorf("OnCreate(); got %v, expected %v", keys, exp)
+	}
+	for _, d := range durations {
+		if d < 10*time.Millisecond {
+			t.Errorf("OnCreate(); got %v, expected >= 10ms", d)
+		}
+	}
+}
+
+func TestCacheOnThrash(t *testing.T) {
+	tests := []struct {
+		opts lru.Options
+		keys []string
+		exp  []float64
+	}{
+		{
+			opts: lru.Options{Capacity: 1, ThrashWindow: 2},
+			keys: []string{"key_1", "key_2", "key_3", "key_4", "key_5"},
+			exp:  []float64{1},
+		},
+		{
+			opts: lru.Options{Capacity: 1},
+			keys: []string{"key_1", "key_2", "key_3", "key_4", "key_5"},
+			exp:  []float64{},
+		},
+		{
+			opts: lru.Options{Capacity: 2, ThrashWindow: 2},
+			keys: []string{"key_1", "key_1", "key_1", "key_1", "key_1"},
+			exp:  []float64{},
+		},
+		{
+			opts: lru.Options{Capacity: 2, ThrashWindow: 3},
+			keys: []string{"key_1", "key_2", "key_3", "key_1", "key_2", "key_3"},
+			exp:  []float64{1},
+		},
+		{
+			opts: lru.Options{Capacity: 2, ThrashWindow: 4, ThrashRate: 0.25},
+			keys: []string{"key_1", "key_2", "key_1", "key_3", "key_3", "key_3", "key_3", "key_3"},
+			exp:  []float64{0.25},
+		},
+	}
+
+	for tn, tt := range tests {
+		act := []float64{}
+
+		c := lru.NewCache(tt.opts)
+		c.OnThrash = func(rate float64) {
+			act = append(act, rate)
+		}
+
+		for _, k := range tt.keys {
+			c.GetOrAddFunc(k, 0, func() (interface{}, error) {
+				return k, nil
+			})
+		}
+
+		if fmt.Sprint(act) != fmt.Sprint(tt.exp) {
+			t.Errorf("OnThrash(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestStatsHitRatio(t *testing.T) {
+	tests := []struct {
+		stats lru.Stats
+		exp   float64
+	}{
+		{stats: lru.Stats{}, exp: 0},
+		{stats: lru.Stats{Hits: 3, Misses: 1}, exp: 0.75},
+		{stats: lru.Stats{Misses: 2}, exp: 0},
+	}
+
+	for tn, tt := range tests {
+		if act := tt.stats.HitRatio(); act != tt.exp {
+			t.Errorf("HitRatio(%d); got %v, expected %v", tn, act, tt.exp)
+		}
+	}
+}
+
+func TestStatsMarshalJSON(t *testing.T) {
+	st := lru.Stats{
+		Hits:           3,
+		Misses:         1,
+		Evictions:      2,
+		Len:            4,
+		Bytes:          5,
+		GhostHits:      8,
+		AvgEvictionAge: 6 * time.Nanosecond,
+		MaxEvictionAge: 7 * time.Nanosecond,
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		t.Fatalf("Marshal(); got %v, expected nil", err)
+	}
+
+	exp := `{"hits":3,"misses":1,"evictions":2,"len":4,"bytes":5,"ghost_hits":8,"avg_eviction_age_ns":6,"max_eviction_age_ns":7,"hit_ratio":0.75}`
+	if act := string(b); act != exp {
+		t.Errorf("Marshal(); got %s, expected %s", act, exp)
+	}
+
+	var act lru.Stats
+	if err := json.Unmarshal(b, &act); err != nil || act != st {
+		t.Errorf("Unmarshal(); got %+v, %v, expected %+v, nil", act, err, st)
+	}
+}
+
+func TestCacheOnLockWait(t *testing.T) {
+	tests := []struct {
+		threshold time.Duration
+		exp       bool
+	}{
+		{threshold: 0, exp: true},
+		{threshold: 10 * time.Millisecond, exp: true},
+		{threshold: 1 * time.Minute, exp: false},
+	}
+
+	for tn, tt := range tests {
+		c := lru.NewCache(lru.Options{
+			Capacity:          1,
+			LockWaitThreshold: tt.threshold,
+		})
+
+		var waits []time.Duration
+		c.OnLockWait = func(d time.Duration) {
+			waits = append(waits, d)
+		}
+
+		// hold the lock while the item is evicted
+		held := make(chan struct{})
+		c.ItemEvicted = func(*lru.Item, lru.EvictReason) {
+			close(held)
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		c.Set("key_1", 1, 0)
+		go c.Set("key_2", 2, 0)
+		<-held
+
+		if _, err := c.GetOrAddFunc("key_2", 0, func() (interface{}, error) {
+			return 2, nil
+		}); err != nil {
+			t.Errorf("GetOrAddFunc(%d); got %v, expected nil", tn, err)
+		}
+
+		if act := len(waits) == 1; act != tt.exp {
+			t.Errorf("OnLockWait(%d); got %v, expected invocation %v", tn, waits, tt.exp)
+		}
+		if tt.exp && len(waits) == 1 && waits[0] < 10*time.Millisecond {
+			t.Errorf("OnLockWait(%d); got %v, expected >= 10ms", tn, waits[0])
+		}
+	}
+}
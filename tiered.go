@@ -0,0 +1,164 @@
+package lru
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// TieredCache represents a multi-level cache
+	TieredCache = TypedTieredCache[string, interface{}]
+
+	// Tier represents a level of a multi-level cache
+	Tier = TypedTier[string, interface{}]
+)
+
+// TypedTier represents a level of a typed multi-level cache
+type TypedTier[K comparable, V any] struct {
+	Cache *TypedCache[K, V]
+
+	// TTL is the TTL of items added to the tier. If zero then the request TTL is used.
+	TTL time.Duration
+}
+
+// NewTieredCache returns a new multi-level cache with the specified tiers
+func NewTieredCache(tiers ...Tier) *TieredCache {
+	return NewTypedTieredCache(tiers...)
+}
+
+// NewTypedTieredCache returns a new typed multi-level cache with the specified tiers,
+// which are accessed in order. If no tiers are specified then a single tier with the
+// default options is used. The tiers are not closed by the multi-level cache.
+func NewTypedTieredCache[K comparable, V any](tiers ...TypedTier[K, V]) *TypedTieredCache[K, V] {
+	if len(tiers) < 1 {
+		tiers = []TypedTier[K, V]{{Cache: NewTypedCache(TypedOptions[K, V]{})}}
+	}
+
+	return &TypedTieredCache[K, V]{tiers: tiers}
+}
+
+// TypedTieredCache represents a typed multi-level cache, such as a small cache with a
+// short TTL in front of a larger cache with a longer TTL. A miss in a tier is served by
+// the next tier and the result is added to each tier that missed, so a hit in a lower
+// tier is promoted to the tiers above it. The request create func is only invoked if
+// the key is not cached in any tier. Each tier deduplicates concurrent requests for the
+// same key, as with GetOrAdd.
+type TypedTieredCache[K comparable, V any] struct {
+	tiers []TypedTier[K, V]
+}
+
+// GetOrAdd returns the value from the first tier that caches the request key. If no
+// tier caches the key then the create func is invoked and the result added to all tiers.
+// Each tier uses its own TTL if set, otherwise the request TTL. If the request has a
+// CreateWithTTL func then the returned TTL only applies to the last tier. Promoted items
+// are added with the tier TTL, so they may outlive the item in the lower tier.
+func (c *TypedTieredCache[K, V]) GetOrAdd(r *TypedGetOrAdd[K, V]) error {
+	return c.GetOrAddContext(context.Background(), r)
+}
+
+// GetOrAddFunc is equivalent to GetOrAdd, but builds the request from the specified
+// key, TTL and create func and returns the result
+func (c *TypedTieredCache[K, V]) GetOrAddFunc(key K, ttl time.Duration, create func() (V, error)) (V, error) {
+	r := TypedGetOrAdd[K, V]{Key: key, TTL: ttl, Create: create}
+	err := c.GetOrAdd(&r)
+
+	return r.Result, err
+}
+
+// GetOrAddWithTTLFunc is equivalent to GetOrAdd, but builds the request from the
+// specified key and create func, which returns the TTL for the created value
+func (c *TypedTieredCache[K, V]) GetOrAddWithTTLFunc(key K, create func() (V, time.Duration, error)) (V, error) {
+	r := TypedGetOrAdd[K, V]{Key: key, CreateWithTTL: create}
+	err := c.GetOrAdd(&r)
+
+	return r.Result, err
+}
+
+// GetOrAddContext is equivalent to GetOrAdd, but returns the context error if the
+// context is cancelled before the result is available
+func (c *TypedTieredCache[K, V]) GetOrAddContext(ctx context.Context, r *TypedGetOrAdd[K, V]) error {
+	r.Created = false
+
+	// the lower tiers may be accessed after the request returns if the context is done
+	cr := *r
+
+	var created atomic.Bool
+	v, err := c.getOrAdd(ctx, 0, &cr, &created)
+	if err != nil {
+		return err
+	}
+
+	r.Result = v
+	r.Created = created.Load()
+	return nil
+}
+
+// getOrAdd returns the value from the tier with the specified index, which creates
+// missing values using the next tier, or the request create func if it is the last tier
+func (c *TypedTieredCache[K, V]) getOrAdd(ctx context.Context, idx int, r *TypedGetOrAdd[K, V], created *atomic.Bool) (V, error) {
+	t := c.tiers[idx]
+
+	tr := TypedGetOrAdd[K, V]{
+		Key:    r.Key,
+		TTL:    r.TTL,
+		Weight: r.Weight,
+		Policy: r.Policy,
+		Tags:   r.Tags,
+		Force:  r.Force,
+	}
+	if t.TTL != 0 {
+		tr.TTL = t.TTL
+	}
+
+	last := idx == len(c.tiers)-1
+	if last {
+		tr.Create = r.Create
+		tr.CreateContext = r.CreateContext
+		tr.CreateWithTTL = r.CreateWithTTL
+	} else {
+		tr.CreateContext = func(ctx context.Context) (V, error) {
+			return c.getOrAdd(ctx, idx+1, r, created)
+		}
+	}
+
+	err := t.Cache.GetOrAddContext(ctx, &tr)
+	if last && tr.Created {
+		created.Store(true)
+	}
+
+	return tr.Result, err
+}
+
+// Set adds the value to all tiers with the tier TTL, or the specified TTL if the tier
+// TTL is not set
+func (c *TypedTieredCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	for _, t := range c.tiers {
+		tt := ttl
+		if t.TTL != 0 {
+			tt = t.TTL
+		}
+
+		t.Cache.Set(key, value, tt)
+	}
+}
+
+// Remove removes the item with the specified key from all tiers and returns true if
+// it existed in any tier
+func (c *TypedTieredCache[K, V]) Remove(key K) bool {
+	var ok bool
+	for _, t := range c.tiers {
+		if t.Cache.Remove(key) {
+			ok = true
+		}
+	}
+
+	return ok
+}
+
+// Clear removes all items from all tiers
+func (c *TypedTieredCache[K, V]) Clear() {
+	for _, t := range c.tiers {
+		t.Cache.Clear()
+	}
+}
@@ -0,0 +1,179 @@
+package lru_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lru "github.com/stevecallear/go-lru"
+)
+
+func TestTieredCacheGetOrAdd(t *testing.T) {
+	createErr := errors.New("error")
+
+	tests := []struct {
+		l1       interface{}
+		l2       interface{}
+		err      error
+		exp      interface{}
+		created  bool
+		contains []bool
+	}{
+		{
+			exp:      "value",
+			created:  true,
+			contains: []bool{true, true},
+		},
+		{
+			l1:       "l1",
+			l2:       "l2",
+			exp:      "l1",
+			contains: []bool{true, true},
+		},
+		{
+			l2:       "l2",
+			exp:      "l2",
+			contains: []bool{true, true},
+		},
+		{
+			l1:       "l1",
+			exp:      "l1",
+			contains: []bool{true, false},
+		},
+		{
+			err:      createErr,
+			exp:      nil,
+			contains: []bool{false, false},
+		},
+	}
+
+	for tn, tt := range tests {
+		l1 := lru.NewCache(lru.Options{})
+		l2 := lru.NewCache(lru.Options{})
+		if tt.l1 != nil {
+			l1.Set("key", tt.l1, 1*time.Minute)
+		}
+		if tt.l2 != nil {
+			l2.Set("key", tt.l2, 1*time.Minute)
+		}
+
+		sut := lru.NewTieredCache(lru.Tier{Cache: l1}, lru.Tier{Cache: l2})
+
+		r := lru.GetOrAdd{
+			Key: "key",
+			TTL: 1 * time.Minute,
+			Create: func() (interface{}, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return "value", nil
+			},
+		}
+
+		if err := sut.GetOrAdd(&r); err != tt.err {
+			t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, err, tt.err)
+		}
+		if r.Result != tt.exp {
+			t.Errorf("GetOrAdd(%d); got %v, expected %v", tn, r.Result, tt.exp)
+		}
+		if r.Created != tt.created {
+			t.Errorf("Created(%d); got %v, expected %v", tn, r.Created, tt.created)
+		}
+
+		for idx, c := range []*lru.Cache{l1, l2} {
+			if act, exp := c.Contains("key"), tt.contains[idx]; act != exp {
+				t.Errorf("Contains(%d, %d); got %v, expected %v", tn, idx, act, exp)
+			}
+		}
+	}
+}
+
+func TestTieredCacheTTL(t *testing.T) {
+	o := lru.Options{Policy: lru.NewFixedExpirationPolicy()}
+	l1 := lru.NewCache(o)
+	l2 := lru.NewCache(o)
+	l3 := lru.NewCache(o)
+
+	sut := lru.NewTieredCache(
+		lru.Tier{Cache: l1, TTL: 1 * time.Minute},
+		lru.Tier{Cache: l2},
+		lru.Tier{Cache: l3, TTL: 1 * time.Hour},
+	)
+
+	now := time.Now().UTC()
+	fixTime(now, func() {
+		_, err := sut.GetOrAddFunc("get", 10*time.Minute, func() (interface{}, error) {
+			return "value", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sut.Set("set", "value", 10*time.Minute)
+	})
+
+	exp := []time.Duration{1 * time.Minute, 10 * time.Minute, 1 * time.Hour}
+	fixTime(now, func() {
+		for _, key := range []string{"get", "set"} {
+			for idx, c := range []*lru.Cache{l1, l2, l3} {
+				act, ok := c.TTL(key)
+				if !ok || act != exp[idx] {
+					t.Errorf("TTL(%s, %d); got %v, expected %v", key, idx, act, exp[idx])
+				}
+			}
+		}
+	})
+}
+
+func TestTieredCacheRemove(t *testing.T) {
+	l1 := lru.NewCache(lru.Options{})
+	l2 := lru.NewCache(lru.Options{})
+	sut := lru.NewTieredCache(lru.Tier{Cache: l1}, lru.Tier{Cache: l2})
+
+	sut.Set("key", "value", 1*time.Minute)
+	l1.Remove("key")
+
+	if ok := sut.Remove("key"); !ok {
+		t.Errorf("Remove(); got %v, expected %v", ok, true)
+	}
+
+	if ok := sut.Remove("key"); ok {
+		t.Errorf("Remove(); got %v, expected %v", ok, false)
+	}
+
+	if l1.Len() != 0 || l2.Len() != 0 {
+		t.Errorf("Len(); got %d and %d, expected %d", l1.Len(), l2.Len(), 0)
+	}
+}
+
+func TestTieredCacheConcurrency(t *testing.T) {
+	sut := lru.NewTieredCache(
+		lru.Tier{Cache: lru.NewCache(lru.Options{})},
+		lru.Tier{Cache: lru.NewCache(lru.Options{})},
+	)
+
+	var n int32
+	var wg sync.WaitGroup
+	for idx := 0; idx < 10; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := sut.GetOrAddFunc("key", 1*time.Minute, func() (interface{}, error) {
+				atomic.AddInt32(&n, 1)
+				time.Sleep(10 * time.Millisecond)
+				return "value", nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if act := atomic.LoadInt32(&n); act != 1 {
+		t.Errorf("Create(); got %d, expected %d", act, 1)
+	}
+}